@@ -20,7 +20,8 @@ echo "  - mcp-filesystem-server (raw implementation)"
 echo "  - mcp-filesystem-server-mark3labs-mcp-go (SDK implementation)"
 echo ""
 echo "Usage:"
-echo "  Both servers accept a -dir argument to specify the base directory"
+echo "  Both servers accept a -dir argument to specify the base directory."
+echo "  -dir may be repeated as [name=]path[:ro|:rw] to allow several directories."
 echo ""
 echo "Examples:"
 echo "  # Use current directory as base"
@@ -33,4 +34,11 @@ echo "  ./mcp-filesystem-server-mark3labs-mcp-go -dir /path/to/directory"
 echo ""
 echo "  # Use relative directory"
 echo "  ./mcp-filesystem-server -dir ./my-files"
-echo "  ./mcp-filesystem-server-mark3labs-mcp-go -dir ./my-files"
\ No newline at end of file
+echo "  ./mcp-filesystem-server-mark3labs-mcp-go -dir ./my-files"
+echo ""
+echo "  # Allow a repository read-write and shared docs read-only"
+echo "  ./mcp-filesystem-server -dir ./repo -dir docs=/shared/docs:ro"
+echo "  ./mcp-filesystem-server-mark3labs-mcp-go -dir ./repo -dir docs=/shared/docs:ro"
+echo ""
+echo "  # Serve the raw implementation over Streamable HTTP at http://127.0.0.1:8080/mcp"
+echo "  ./mcp-filesystem-server -dir ./repo -transport=http -addr 127.0.0.1:8080"
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// requestIDHeader carries the key of a tools/call request from the
+// BeforeCallTool hook, which sees the JSON-RPC id, to the tool middleware,
+// which does not
+const requestIDHeader = "X-Mcp-Request-Key"
+
+// inFlight maps the key of each running tool call to the function that cancels it
+var inFlight = struct {
+	sync.Mutex
+	byKey map[string]context.CancelFunc
+}{byKey: make(map[string]context.CancelFunc)}
+
+// addCancellation makes tool calls abortable with notifications/cancelled,
+// which the SDK does not handle itself. Only tools/call is covered: the SDK
+// runs other requests inline in its read loop, so they finish before a
+// cancellation could be read. The SDK still answers a cancelled call.
+func addCancellation(s *server.MCPServer, hooks *server.Hooks) {
+	hooks.AddBeforeCallTool(func(ctx context.Context, id any, message *mcp.CallToolRequest) {
+		header := message.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set(requestIDHeader, requestKey(ctx, id))
+		message.Header = header
+	})
+
+	server.WithToolHandlerMiddleware(func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			key := request.Header.Get(requestIDHeader)
+			if key == "" {
+				return next(ctx, request)
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			inFlight.Lock()
+			inFlight.byKey[key] = cancel
+			inFlight.Unlock()
+
+			defer func() {
+				inFlight.Lock()
+				delete(inFlight.byKey, key)
+				inFlight.Unlock()
+				cancel()
+			}()
+
+			return next(ctx, request)
+		}
+	})(s)
+
+	s.AddNotificationHandler("notifications/cancelled", func(ctx context.Context, notification mcp.JSONRPCNotification) {
+		id, ok := notification.Params.AdditionalFields["requestId"]
+		if !ok {
+			return
+		}
+		key := requestKey(ctx, id)
+
+		inFlight.Lock()
+		cancel, ok := inFlight.byKey[key]
+		inFlight.Unlock()
+
+		// Cancellations of finished requests are ignored
+		if ok {
+			reason, _ := notification.Params.AdditionalFields["reason"].(string)
+			log.Printf("Cancelled request %s: %s", key, reason)
+			cancel()
+		}
+	})
+}
+
+// requestKey identifies a request by its client session and JSON-RPC id
+func requestKey(ctx context.Context, id any) string {
+	key := mcp.NewRequestId(id).String()
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		key = session.SessionID() + "/" + key
+	}
+	return key
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// addProgress makes long-running tool calls that carry a progress token send
+// notifications/progress to the client
+func addProgress(s *server.MCPServer) {
+	server.WithToolHandlerMiddleware(func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			meta := request.Params.Meta
+			if meta == nil || meta.ProgressToken == nil {
+				return next(ctx, request)
+			}
+
+			ctx = filesystem.WithProgress(ctx, func(progress, total int64) {
+				params := map[string]any{
+					"progressToken": meta.ProgressToken,
+					"progress":      progress,
+				}
+				if total > 0 {
+					params["total"] = total
+				}
+				if err := s.SendNotificationToClient(ctx, "notifications/progress", params); err != nil {
+					log.Printf("Error sending progress: %v", err)
+				}
+			})
+			return next(ctx, request)
+		}
+	})(s)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-filesystem-server/internal/protocol"
+)
+
+// protocolVersions maps each client session to the version the SDK negotiated with it
+var protocolVersions sync.Map
+
+// addProtocolGating hides tool features from clients that negotiated a
+// protocol version predating them. The SDK negotiates the version but
+// describes tools and results the same way to every client.
+func addProtocolGating(hooks *server.Hooks) {
+	hooks.AddAfterInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			protocolVersions.Store(session.SessionID(), result.ProtocolVersion)
+		}
+	})
+
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		protocolVersions.Delete(session.SessionID())
+	})
+
+	hooks.AddAfterListTools(func(ctx context.Context, id any, message *mcp.ListToolsRequest, result *mcp.ListToolsResult) {
+		for i := range result.Tools {
+			if !supports(ctx, protocol.ToolAnnotations) {
+				result.Tools[i].Annotations = mcp.ToolAnnotation{}
+			}
+			if !supports(ctx, protocol.StructuredOutput) {
+				result.Tools[i].OutputSchema = mcp.ToolOutputSchema{}
+				result.Tools[i].RawOutputSchema = nil
+			}
+		}
+	})
+
+	hooks.AddAfterCallTool(func(ctx context.Context, id any, message *mcp.CallToolRequest, result *mcp.CallToolResult) {
+		if result != nil && !supports(ctx, protocol.StructuredOutput) {
+			result.StructuredContent = nil
+		}
+	})
+}
+
+// supports reports whether the version negotiated with the client of ctx
+// includes the features introduced in the given revision
+func supports(ctx context.Context, revision string) bool {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return false
+	}
+	version, _ := protocolVersions.Load(session.SessionID())
+	v, _ := version.(string)
+	return protocol.Supports(v, revision)
+}
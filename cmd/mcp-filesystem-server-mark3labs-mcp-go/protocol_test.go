@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-filesystem-server/internal/filesystem"
+	"mcp-filesystem-server/internal/protocol"
+	"mcp-filesystem-server/internal/tools"
+)
+
+// The SDK negotiates the version itself; it must speak the same ones as the raw server
+func TestProtocolVersionsMatchSDK(t *testing.T) {
+	if !slices.Equal(protocol.Versions, mcp.ValidProtocolVersions) {
+		t.Errorf("protocol.Versions is %v but the SDK speaks %v", protocol.Versions, mcp.ValidProtocolVersions)
+	}
+}
+
+func TestProtocolDowngrade(t *testing.T) {
+	v, err := filesystem.NewValidator(filesystem.RootSpec{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	validator = v
+
+	hooks := &server.Hooks{}
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(false), server.WithHooks(hooks))
+	addProtocolGating(hooks)
+	addTools(s, tools.NewFilesystem(v, tools.Options{}))
+
+	tests := []struct {
+		requested   string
+		negotiated  string
+		annotations bool
+		structured  bool
+	}{
+		{requested: "2024-11-05", negotiated: "2024-11-05"},
+		{requested: "2025-03-26", negotiated: "2025-03-26", annotations: true},
+		{requested: "2025-06-18", negotiated: "2025-06-18", annotations: true, structured: true},
+		{requested: "2099-01-01", negotiated: "2025-06-18", annotations: true, structured: true},
+		{requested: "1.0", negotiated: "2025-06-18", annotations: true, structured: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			session := server.NewInProcessSession("session-"+tt.requested, nil)
+			if err := s.RegisterSession(context.Background(), session); err != nil {
+				t.Fatal(err)
+			}
+			defer s.UnregisterSession(context.Background(), session.SessionID())
+			ctx := s.WithContext(context.Background(), session)
+
+			call := func(method string, params interface{}, result interface{}) {
+				t.Helper()
+				request, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+				data, _ := json.Marshal(s.HandleMessage(ctx, request))
+				var response struct {
+					Result json.RawMessage
+					Error  *struct{ Message string }
+				}
+				if err := json.Unmarshal(data, &response); err != nil || response.Error != nil {
+					t.Fatalf("%s failed: %s", method, data)
+				}
+				if err := json.Unmarshal(response.Result, result); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var initialized struct{ ProtocolVersion string }
+			call("initialize", map[string]interface{}{
+				"protocolVersion": tt.requested,
+				"capabilities":    map[string]interface{}{},
+				"clientInfo":      map[string]interface{}{"name": "test", "version": "1"},
+			}, &initialized)
+			if initialized.ProtocolVersion != tt.negotiated {
+				t.Errorf("negotiated %q, want %q", initialized.ProtocolVersion, tt.negotiated)
+			}
+
+			var list struct {
+				Tools []map[string]json.RawMessage
+			}
+			call("tools/list", nil, &list)
+			for _, tool := range list.Tools {
+				name := string(tool["name"])
+				// The SDK always sends annotations, as an empty object when cleared
+				annotations, ok := tool["annotations"]
+				if got := ok && string(annotations) != "{}"; got != tt.annotations {
+					t.Errorf("%s: annotations sent %v, want %v", name, got, tt.annotations)
+				}
+				if _, got := tool["outputSchema"]; got && !tt.structured {
+					t.Errorf("%s: output schema sent to a %s client", name, tt.negotiated)
+				}
+			}
+
+			var result map[string]json.RawMessage
+			call("tools/call", map[string]interface{}{"name": "list_allowed_directories", "arguments": map[string]interface{}{}}, &result)
+			if _, got := result["structuredContent"]; got != tt.structured {
+				t.Errorf("structured content sent %v, want %v", got, tt.structured)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-filesystem-server/internal/filesystem"
+	"mcp-filesystem-server/internal/watcher"
+)
+
+// fileWatcher is nil when watching is disabled or unsupported
+var fileWatcher *watcher.Watcher
+
+// maxListedResources caps the number of files registered as resources
+var maxListedResources = filesystem.MaxListedResources
+
+// listTruncated is set when the registered resources stop at maxListedResources
+var listTruncated atomic.Bool
+
+// addResources exposes the files in the allowed directories as resources.
+// The SDK only lists statically registered resources, so the registered set
+// is kept in sync with the disk: from file watcher events when watching, in
+// which case the SDK also sends notifications/resources/list_changed, or
+// otherwise before every resources/list. Reads of any file, listed or not,
+// go through the file:// resource template. The SDK does not implement
+// resources/subscribe. At most maxListedResources files are registered; when
+// there are more, the last page of resources/list ends with an entry saying so.
+func addResources(s *server.MCPServer, hooks *server.Hooks) {
+	refresh := func() {
+		var ignore *filesystem.IgnoreFilter
+		if cfg.HonorIgnoreFiles {
+			ignore = validator.NewIgnoreFilter()
+		}
+
+		files, next, err := validator.ListFileResources(context.Background(), ignore, "", maxListedResources)
+		if err != nil {
+			log.Printf("Error listing resources: %v", err)
+			return
+		}
+		if next != "" {
+			log.Printf("Listing only the first %d files as resources", len(files))
+		}
+		listTruncated.Store(next != "")
+
+		resources := make([]server.ServerResource, 0, len(files))
+		for _, file := range files {
+			resources = append(resources, server.ServerResource{
+				Resource: mcp.NewResource(file.URI, file.Name, mcp.WithMIMEType(file.MIMEType)),
+				Handler:  readResource,
+			})
+		}
+		s.SetResources(resources...)
+	}
+
+	fileWatcher = cfg.StartWatcher(validator, func(events []watcher.Event) {
+		for _, event := range events {
+			if event.Op&(watcher.Create|watcher.Remove) != 0 {
+				refresh()
+				return
+			}
+		}
+	})
+
+	if fileWatcher != nil {
+		// Only advertise listChanged once watching works: SetResources
+		// notifies on every call, which would make clients that re-list on
+		// notification loop if refresh ran before each list
+		server.WithResourceCapabilities(false, true)(s)
+		refresh()
+	} else {
+		hooks.AddBeforeListResources(func(ctx context.Context, id any, message *mcp.ListResourcesRequest) {
+			refresh()
+		})
+	}
+
+	hooks.AddAfterListResources(func(ctx context.Context, id any, message *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+		if result.NextCursor == "" && listTruncated.Load() {
+			result.Resources = append(result.Resources, truncatedResource())
+		}
+	})
+
+	fileTemplate := mcp.NewResourceTemplate(filesystem.FileURITemplate, "file",
+		mcp.WithTemplateDescription("A file in one of the allowed directories, addressed by its absolute path"),
+	)
+	s.AddResourceTemplate(fileTemplate, readResource)
+}
+
+// truncatedResource is the entry ending a resource list cut short, which the
+// SDK has no other way to tell clients about
+func truncatedResource() mcp.Resource {
+	return mcp.NewResource(filesystem.FileURI(validator.GetBaseDir()),
+		fmt.Sprintf("Only the first %d files are listed", maxListedResources),
+		mcp.WithResourceDescription("Read other files through the file:// resource template, or find them with search_files"),
+	)
+}
+
+// readResource reads a file resource as text or, for binary files, as a base64 blob
+func readResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	contents, err := validator.ReadFileResource(ctx, request.Params.URI, cfg.MaxReadBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if contents.Text != nil {
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      contents.URI,
+				MIMEType: contents.MIMEType,
+				Text:     *contents.Text,
+			},
+		}, nil
+	}
+
+	return []mcp.ResourceContents{
+		mcp.BlobResourceContents{
+			URI:      contents.URI,
+			MIMEType: contents.MIMEType,
+			Blob:     *contents.Blob,
+		},
+	}, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+func TestListResourcesTruncated(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := filesystem.NewValidator(filesystem.RootSpec{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	validator = v
+	defer func(max int) { maxListedResources = max }(maxListedResources)
+
+	hooks := &server.Hooks{}
+	s := server.NewMCPServer("test", "1.0.0", server.WithResourceCapabilities(false, false), server.WithHooks(hooks))
+	addResources(s, hooks)
+
+	list := func() []string {
+		t.Helper()
+		data, _ := json.Marshal(s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)))
+		var response struct {
+			Result struct {
+				Resources []struct{ Name string }
+			}
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, resource := range response.Result.Resources {
+			names = append(names, resource.Name)
+		}
+		return names
+	}
+
+	maxListedResources = 2
+	base := filepath.Base(dir)
+	want := []string{base + "/a.txt", base + "/b.txt", "Only the first 2 files are listed"}
+	if got := list(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	maxListedResources = 3
+	want = []string{base + "/a.txt", base + "/b.txt", base + "/c.txt"}
+	if got := list(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
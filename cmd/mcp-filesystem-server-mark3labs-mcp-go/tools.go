@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-filesystem-server/internal/tools"
+)
+
+// addTools registers every tool of the registry with the SDK server
+func addTools(s *server.MCPServer, registry *tools.Registry) {
+	for _, tool := range registry.Tools() {
+		inputSchema, _ := json.Marshal(tool.InputSchema)
+		t := mcp.NewToolWithRawSchema(tool.Name, tool.Description, inputSchema)
+		t.Annotations = mcp.ToolAnnotation{
+			Title:           tool.Annotations.Title,
+			ReadOnlyHint:    mcp.ToBoolPtr(tool.Annotations.ReadOnly),
+			DestructiveHint: mcp.ToBoolPtr(tool.Annotations.Destructive),
+			IdempotentHint:  mcp.ToBoolPtr(tool.Annotations.Idempotent),
+			OpenWorldHint:   mcp.ToBoolPtr(tool.Annotations.OpenWorld),
+		}
+		if tool.OutputSchema != nil {
+			t.RawOutputSchema, _ = json.Marshal(tool.OutputSchema)
+		}
+
+		name := tool.Name
+		s.AddTool(t, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := registry.Call(ctx, name, request.GetArguments())
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return toCallToolResult(result), nil
+		})
+	}
+}
+
+// toCallToolResult converts a tool result to the SDK's type
+func toCallToolResult(result *tools.Result) *mcp.CallToolResult {
+	r := &mcp.CallToolResult{
+		StructuredContent: result.Structured,
+		IsError:           result.IsError,
+	}
+	for _, content := range result.Content {
+		switch content.Type {
+		case "image":
+			r.Content = append(r.Content, mcp.NewImageContent(content.Data, content.MIMEType))
+		case "resource":
+			r.Content = append(r.Content, mcp.NewEmbeddedResource(mcp.BlobResourceContents{
+				URI:      content.Resource.URI,
+				MIMEType: content.Resource.MIMEType,
+				Blob:     content.Resource.Blob,
+			}))
+		default:
+			r.Content = append(r.Content, mcp.NewTextContent(content.Text))
+		}
+	}
+	return r
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+type CancelledParams struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// errRequestCancelled is the cancellation cause of requests aborted by the client
+var errRequestCancelled = errors.New("request cancelled by client")
+
+// requestKey encodes a JSON-RPC id so that string and numeric ids never collide
+func requestKey(id interface{}) string {
+	key, _ := json.Marshal(id)
+	return string(key)
+}
+
+// begin registers a running request with the session and returns its
+// context along with a function to call when the request is done
+func (s *session) begin(ctx context.Context, id interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := requestKey(id)
+
+	s.mu.Lock()
+	s.inFlight[key] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.inFlight, key)
+		s.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel aborts a running request; it reports false when the request is
+// unknown or already finished
+func (s *session) cancel(id interface{}) bool {
+	s.mu.Lock()
+	cancel, ok := s.inFlight[requestKey(id)]
+	s.mu.Unlock()
+
+	if ok {
+		cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+// requestCancelled reports whether the client cancelled the request running under ctx
+func requestCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRequestCancelled)
+}
+
+// handleCancelled aborts the request named by a notifications/cancelled
+// notification. Cancellations of unknown or finished requests are ignored,
+// as the request may have completed before the notification arrived.
+func handleCancelled(sess *session, request JSONRPCRequest) {
+	var params CancelledParams
+	paramBytes, _ := json.Marshal(request.Params)
+	json.Unmarshal(paramBytes, &params)
+
+	if params.RequestID == nil {
+		return
+	}
+
+	if sess.cancel(params.RequestID) {
+		log.Printf("Cancelled request %s: %s", requestKey(params.RequestID), params.Reason)
+	}
+}
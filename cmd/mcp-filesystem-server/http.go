@@ -0,0 +1,443 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-filesystem-server/internal/protocol"
+)
+
+const (
+	// httpEndpoint is the single MCP endpoint of the Streamable HTTP transport
+	httpEndpoint = "/mcp"
+
+	// sessionHeader carries the session ID assigned in the initialize response
+	sessionHeader = "Mcp-Session-Id"
+
+	// protocolHeader carries the negotiated protocol version on every request
+	// after initialize, from revision 2025-06-18 on
+	protocolHeader = "Mcp-Protocol-Version"
+
+	// maxRequestBody bounds the size of a POSTed JSON-RPC message
+	maxRequestBody = 16 << 20
+
+	// sessionIdleTimeout expires sessions whose client stopped talking to us
+	// without sending DELETE
+	sessionIdleTimeout = 30 * time.Minute
+
+	// streamKeepAlive is how often an idle SSE stream gets a comment line so
+	// that proxies do not close it
+	streamKeepAlive = 30 * time.Second
+
+	// outboxSize is how many notifications are buffered per session while no
+	// SSE stream is open; further notifications are dropped
+	outboxSize = 64
+)
+
+// httpSession is a session served over HTTP. Notifications wait in outbox
+// until the client opens an SSE stream with GET; done is closed when the
+// session ends.
+type httpSession struct {
+	*session
+	outbox chan JSONRPCNotification
+	done   chan struct{}
+}
+
+// httpSessions maps session IDs to their HTTP state; the session itself is
+// also registered in sessions so the file watcher reaches it
+var httpSessions = struct {
+	sync.Mutex
+	byID map[string]*httpSession
+}{byID: make(map[string]*httpSession)}
+
+// httpAccess decides which HTTP requests reach the MCP endpoint. The
+// transport has no user accounts: a request is served when its Host and
+// Origin are trusted and, if a token is set, it carries that bearer token.
+type httpAccess struct {
+	// Hosts lists host names or addresses besides loopback ones and the
+	// listen address that clients may use to reach the server
+	Hosts []string
+	// Origins lists browser origins such as https://app.example.com allowed
+	// besides loopback ones
+	Origins []string
+	// Token, when set, must be sent as "Authorization: Bearer <token>"
+	Token string
+}
+
+// serveHTTP serves the MCP Streamable HTTP transport on addr. Each client
+// initializes its own session, identified by the Mcp-Session-Id header.
+func serveHTTP(addr string, access httpAccess) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			access.Hosts = append(access.Hosts, host)
+		}
+		if !isLoopbackHost(host) && access.Token == "" {
+			log.Printf("Warning: listening on %s without -auth-token; anyone who can reach it can use the allowed directories", addr)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(httpEndpoint, access.guard(http.HandlerFunc(handleHTTP)))
+
+	go expireSessions()
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("MCP Filesystem Server listening on http://%s%s", addr, httpEndpoint)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("HTTP server error: %v", err)
+	}
+}
+
+// guard refuses requests with an untrusted Host or Origin header or without
+// the bearer token. A DNS rebinding attack makes a browser send requests for
+// an attacker's host name to the local server, so both the Host, which then
+// names the attacker's domain, and the Origin are checked against fixed lists
+// instead of against each other.
+func (a httpAccess) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.allowedHost(r.Host) {
+			http.Error(w, "Forbidden host", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !a.allowedOrigin(origin) {
+			http.Error(w, "Forbidden origin", http.StatusForbidden)
+			return
+		}
+		if a.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether the Host header, with or without a port, names
+// a loopback host or one of the configured hosts
+func (a httpAccess) allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return isLoopbackHost(host) || slices.ContainsFunc(a.Hosts, func(allowed string) bool {
+		return strings.EqualFold(host, allowed)
+	})
+}
+
+// allowedOrigin reports whether a browser origin is a loopback one or one of
+// the configured origins
+func (a httpAccess) allowedOrigin(origin string) bool {
+	if slices.ContainsFunc(a.Origins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	}) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && isLoopbackHost(u.Hostname())
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback address
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func handleHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		handleHTTPPost(w, r)
+	case http.MethodGet:
+		handleHTTPStream(w, r)
+	case http.MethodDelete:
+		handleHTTPDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHTTPPost handles one JSON-RPC message or batch. Requests are answered
+// in the response body, as JSON or as a single SSE event depending on the
+// Accept header; notifications and responses are acknowledged with 202 Accepted.
+func handleHTTPPost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	messages, batch := parseMessages(body)
+	if len(messages) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if !batch && messages[0].invalid != nil {
+		writeJSON(w, http.StatusBadRequest, messages[0].invalid)
+		return
+	}
+
+	var hs *httpSession
+	if !batch && messages[0].request.Method == "initialize" {
+		hs = newHTTPSession()
+		w.Header().Set(sessionHeader, hs.id)
+	} else {
+		var status int
+		hs, status = requireSession(r)
+		if hs == nil {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	hs.touch()
+
+	// Notifications about the request, such as progress, go out on the
+	// response's own SSE stream when the client accepts one
+	ctx := r.Context()
+	stream := &eventStream{w: w}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		ctx = withNotifier(ctx, stream.notify)
+	}
+
+	responses := handleMessages(ctx, hs.session, messages)
+	if len(responses) == 0 {
+		if !stream.started {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		return
+	}
+
+	var response interface{} = responses[0]
+	if batch {
+		response = responses
+	}
+
+	if stream.started || prefersEventStream(r) {
+		stream.start()
+		if err := writeEvent(w, response); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// eventStream turns a POST response into an SSE stream the first time a
+// notification about the request is sent. It is used only by the goroutine
+// handling the request.
+type eventStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *eventStream) start() {
+	if s.started {
+		return
+	}
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+	s.started = true
+}
+
+func (s *eventStream) notify(notification JSONRPCNotification) {
+	s.start()
+	if err := writeEvent(s.w, notification); err != nil {
+		log.Printf("Error encoding notification: %v", err)
+	}
+}
+
+// handleHTTPStream opens an SSE stream over which the session's
+// notifications are delivered until the client disconnects
+func handleHTTPStream(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	hs, status := requireSession(r)
+	if hs == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-hs.done:
+			// The session was deleted or expired
+			return
+		case notification := <-hs.outbox:
+			if err := writeEvent(w, notification); err != nil {
+				log.Printf("Error encoding notification: %v", err)
+				return
+			}
+		case <-keepAlive.C:
+			hs.touch()
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleHTTPDelete terminates a session at the client's request
+func handleHTTPDelete(w http.ResponseWriter, r *http.Request) {
+	hs, status := requireSession(r)
+	if hs == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	hs.close()
+	w.WriteHeader(http.StatusOK)
+}
+
+// newHTTPSession creates a session whose notifications are queued for its SSE stream
+func newHTTPSession() *httpSession {
+	outbox := make(chan JSONRPCNotification, outboxSize)
+	hs := &httpSession{outbox: outbox, done: make(chan struct{})}
+	hs.session = newSession(func(notification JSONRPCNotification) {
+		select {
+		case outbox <- notification:
+		default:
+			log.Printf("Dropping %s for session %s: no stream is draining notifications", notification.Method, hs.id)
+		}
+	})
+
+	httpSessions.Lock()
+	httpSessions.byID[hs.id] = hs
+	httpSessions.Unlock()
+
+	return hs
+}
+
+// requireSession returns the session named by the request's Mcp-Session-Id
+// header, or nil with 400 when the header is missing or the request names an
+// unsupported protocol version, and 404 when the session is unknown or
+// terminated, as the transport specification requires
+func requireSession(r *http.Request) (*httpSession, int) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	if version := r.Header.Get(protocolHeader); version != "" && !slices.Contains(protocol.Versions, version) {
+		return nil, http.StatusBadRequest
+	}
+
+	httpSessions.Lock()
+	hs := httpSessions.byID[id]
+	httpSessions.Unlock()
+
+	if hs == nil {
+		return nil, http.StatusNotFound
+	}
+	return hs, http.StatusOK
+}
+
+// close terminates the session and ends any open SSE stream
+func (hs *httpSession) close() {
+	httpSessions.Lock()
+	_, live := httpSessions.byID[hs.id]
+	delete(httpSessions.byID, hs.id)
+	httpSessions.Unlock()
+
+	if live {
+		hs.session.close()
+		close(hs.done)
+	}
+}
+
+// expireSessions periodically closes sessions idle for longer than sessionIdleTimeout
+func expireSessions() {
+	for range time.Tick(sessionIdleTimeout / 10) {
+		httpSessions.Lock()
+		var idle []*httpSession
+		for _, hs := range httpSessions.byID {
+			if time.Since(hs.idleSince()) > sessionIdleTimeout {
+				idle = append(idle, hs)
+			}
+		}
+		httpSessions.Unlock()
+
+		for _, hs := range idle {
+			log.Printf("Expiring idle session %s", hs.id)
+			hs.close()
+		}
+	}
+}
+
+// prefersEventStream reports whether the client lists text/event-stream
+// before application/json in its Accept header
+func prefersEventStream(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	sse := strings.Index(accept, "text/event-stream")
+	if sse < 0 {
+		return false
+	}
+	json := strings.Index(accept, "application/json")
+	return json < 0 || sse < json
+}
+
+// writeJSON writes a JSON-RPC message as an application/json response
+func writeJSON(w http.ResponseWriter, status int, message interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeEvent writes a JSON-RPC message as a single SSE event and flushes it
+func writeEvent(w http.ResponseWriter, message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
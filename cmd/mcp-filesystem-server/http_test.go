@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPAccessGuard(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name   string
+		access httpAccess
+		host   string
+		origin string
+		auth   string
+		want   int
+	}{
+		{name: "localhost", host: "localhost:8080", want: http.StatusOK},
+		{name: "loopback IPv4", host: "127.0.0.1:8080", want: http.StatusOK},
+		{name: "loopback IPv6", host: "[::1]:8080", want: http.StatusOK},
+		{name: "loopback origin", host: "127.0.0.1:8080", origin: "http://localhost:3000", want: http.StatusOK},
+		{name: "rebound host", host: "attacker.example:8080", want: http.StatusForbidden},
+		{name: "rebound host with matching origin", host: "attacker.example:8080", origin: "http://attacker.example:8080", want: http.StatusForbidden},
+		{name: "foreign origin", host: "127.0.0.1:8080", origin: "https://attacker.example", want: http.StatusForbidden},
+		{name: "null origin", host: "127.0.0.1:8080", origin: "null", want: http.StatusForbidden},
+		{name: "file origin", host: "127.0.0.1:8080", origin: "file://localhost", want: http.StatusForbidden},
+		{name: "allowed host", access: httpAccess{Hosts: []string{"files.internal"}}, host: "Files.Internal:8080", want: http.StatusOK},
+		{name: "allowed origin", access: httpAccess{Origins: []string{"https://app.example.com/"}}, host: "localhost", origin: "https://app.example.com", want: http.StatusOK},
+		{name: "allowed origin other port", access: httpAccess{Origins: []string{"https://app.example.com"}}, host: "localhost", origin: "https://app.example.com:444", want: http.StatusForbidden},
+		{name: "token", access: httpAccess{Token: "secret"}, host: "localhost", auth: "Bearer secret", want: http.StatusOK},
+		{name: "missing token", access: httpAccess{Token: "secret"}, host: "localhost", want: http.StatusUnauthorized},
+		{name: "wrong token", access: httpAccess{Token: "secret"}, host: "localhost", auth: "Bearer secreT", want: http.StatusUnauthorized},
+		{name: "basic auth", access: httpAccess{Token: "secret"}, host: "localhost", auth: "Basic secret", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, httpEndpoint, nil)
+			r.Host = tt.host
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+
+			w := httptest.NewRecorder()
+			tt.access.guard(ok).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// incomingMessage is one JSON-RPC message received from the client: either a
+// request or notification to handle, or, when the message was malformed, the
+// error response it earned
+type incomingMessage struct {
+	request JSONRPCRequest
+	invalid *JSONRPCResponse
+}
+
+// parseMessages decodes the JSON-RPC messages in data, which holds a single
+// message or a batch array. Malformed messages yield error responses: -32700
+// for invalid JSON and -32600 for JSON that is not a valid request. Responses
+// sent by the client are dropped, as the server never issues requests.
+func parseMessages(data []byte) (messages []incomingMessage, batch bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	if data[0] != '[' {
+		message, ok := parseMessage(data)
+		if !ok {
+			return nil, false
+		}
+		return []incomingMessage{message}, false
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return []incomingMessage{{invalid: errorResponse(nil, -32700, fmt.Sprintf("Parse error: %v", err))}}, false
+	}
+	if len(elements) == 0 {
+		// An empty batch is answered with a single error, not an empty array
+		return []incomingMessage{{invalid: errorResponse(nil, -32600, "Invalid Request: empty batch")}}, false
+	}
+
+	for _, element := range elements {
+		message, ok := parseMessage(element)
+		if !ok {
+			continue
+		}
+		if message.invalid == nil && message.request.Method == "initialize" {
+			message.invalid = errorResponse(message.request.ID, -32600, "Invalid Request: initialize must not be part of a batch")
+		}
+		messages = append(messages, message)
+	}
+	return messages, true
+}
+
+// parseMessage decodes a single JSON-RPC message. It returns false for
+// responses, which are dropped.
+func parseMessage(data []byte) (incomingMessage, bool) {
+	if !json.Valid(data) {
+		return incomingMessage{invalid: errorResponse(nil, -32700, "Parse error: invalid JSON")}, true
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return incomingMessage{invalid: errorResponse(nil, -32600, "Invalid Request: message must be an object")}, true
+	}
+
+	// Keep numeric ids exactly as sent so that the response echoes them verbatim
+	var id interface{}
+	if raw, ok := fields["id"]; ok {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		decoder.Decode(&id)
+
+		switch id.(type) {
+		case string, json.Number:
+		default:
+			return incomingMessage{invalid: errorResponse(nil, -32600, "Invalid Request: id must be a string or number")}, true
+		}
+	}
+
+	_, hasMethod := fields["method"]
+	_, hasResult := fields["result"]
+	_, hasError := fields["error"]
+	if !hasMethod && (hasResult || hasError) {
+		return incomingMessage{}, false
+	}
+
+	var request JSONRPCRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return incomingMessage{invalid: errorResponse(id, -32600, fmt.Sprintf("Invalid Request: %v", err))}, true
+	}
+	request.ID = id
+
+	if request.JSONRPC != "2.0" {
+		return incomingMessage{invalid: errorResponse(id, -32600, `Invalid Request: jsonrpc must be "2.0"`)}, true
+	}
+	if request.Method == "" {
+		return incomingMessage{invalid: errorResponse(id, -32600, "Invalid Request: missing method")}, true
+	}
+
+	return incomingMessage{request: request}, true
+}
+
+// decodeParams decodes the params of a request into v, returning a -32602
+// error response when they do not have the expected shape
+func decodeParams(request JSONRPCRequest, v interface{}) *JSONRPCResponse {
+	if request.Params == nil {
+		return nil
+	}
+
+	paramBytes, _ := json.Marshal(request.Params)
+	if err := json.Unmarshal(paramBytes, v); err != nil {
+		return errorResponse(request.ID, -32602, fmt.Sprintf("Invalid params: %v", err))
+	}
+	return nil
+}
+
+func errorResponse(id interface{}, code int, message string) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &JSONRPCError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// handleMessages handles the messages of a batch one after another and
+// returns the responses to its requests
+func handleMessages(ctx context.Context, sess *session, messages []incomingMessage) []*JSONRPCResponse {
+	return runMessages(sess, startMessages(ctx, sess, messages))
+}
+
+// startedMessage is an incoming message whose request, unless it is a
+// notification or initialize, is registered with the session under ctx
+type startedMessage struct {
+	incomingMessage
+	ctx    context.Context
+	finish func()
+}
+
+// startMessages registers the requests among messages with the session, so
+// that a notifications/cancelled read after them reaches them even while
+// they wait to run
+func startMessages(ctx context.Context, sess *session, messages []incomingMessage) []startedMessage {
+	started := make([]startedMessage, len(messages))
+	for i, message := range messages {
+		started[i] = startedMessage{incomingMessage: message, ctx: ctx, finish: func() {}}
+		if message.invalid == nil && message.request.ID != nil && message.request.Method != "initialize" {
+			started[i].ctx, started[i].finish = sess.begin(ctx, message.request.ID)
+		}
+	}
+	return started
+}
+
+// runMessages handles started messages one after another and returns the
+// responses to their requests
+func runMessages(sess *session, messages []startedMessage) []*JSONRPCResponse {
+	var responses []*JSONRPCResponse
+	for _, message := range messages {
+		response := message.invalid
+		if response == nil {
+			response = handleRequest(message.ctx, sess, message.request)
+		}
+		message.finish()
+		if response != nil {
+			responses = append(responses, response)
+		}
+	}
+	return responses
+}
@@ -1,17 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 
+	"mcp-filesystem-server/internal/config"
 	"mcp-filesystem-server/internal/filesystem"
+	"mcp-filesystem-server/internal/protocol"
+	"mcp-filesystem-server/internal/tools"
 )
 
 type JSONRPCRequest struct {
@@ -28,6 +32,12 @@ type JSONRPCResponse struct {
 	Error   *JSONRPCError `json:"error,omitempty"`
 }
 
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 type JSONRPCError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -63,7 +73,8 @@ type InitializeResult struct {
 }
 
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools     *ToolsCapability     `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
 }
 
 type ToolsCapability struct {
@@ -76,15 +87,25 @@ type ServerInfo struct {
 }
 
 type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	InputSchema InputSchema `json:"inputSchema"`
+	Name         string           `json:"name"`
+	Description  string           `json:"description"`
+	InputSchema  InputSchema      `json:"inputSchema"`
+	OutputSchema *InputSchema     `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
 }
 
 type InputSchema struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
-	Required   []string               `json:"required"`
+	Required   []string               `json:"required,omitempty"`
 }
 
 type ToolsListResult struct {
@@ -94,387 +115,280 @@ type ToolsListResult struct {
 type CallToolParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
 }
 
 type CallToolResult struct {
-	Content []ToolContent `json:"content"`
-	IsError *bool         `json:"isError,omitempty"`
+	Content           []ToolContent `json:"content"`
+	StructuredContent interface{}   `json:"structuredContent,omitempty"`
+	IsError           *bool         `json:"isError,omitempty"`
 }
 
+// ToolContent is one item of a tool result: text, an image or an embedded resource
 type ToolContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string            `json:"type"`
+	Text     *string           `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
 }
 
-var validator *filesystem.Validator
-
-func main() {
-	// Parse command line arguments
-	var baseDir string
-	flag.StringVar(&baseDir, "dir", ".", "Base directory for filesystem operations")
-	flag.Parse()
-
-	// Create validator with the specified directory
-	validator = filesystem.NewValidator(baseDir)
-
-	// Ensure the base directory exists
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		log.Fatalf("Failed to create base directory %s: %v", baseDir, err)
-	}
-
-	log.Printf("MCP Filesystem Server starting with base directory: %s", validator.GetBaseDir())
-
-	decoder := json.NewDecoder(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
-
-	for {
-		var request JSONRPCRequest
-		if err := decoder.Decode(&request); err != nil {
-			if err == io.EOF {
-				break
-			}
-			log.Printf("Error decoding request: %v", err)
-			continue
-		}
-
-		response := handleRequest(request)
-		if response != nil {
-			if err := encoder.Encode(response); err != nil {
-				log.Printf("Error encoding response: %v", err)
-			}
-		}
-	}
+// textContent returns a text item
+func textContent(text string) ToolContent {
+	return ToolContent{Type: "text", Text: &text}
 }
 
-func handleRequest(request JSONRPCRequest) *JSONRPCResponse {
-	switch request.Method {
-	case "initialize":
-		return handleInitialize(request)
-	case "tools/list":
-		return handleToolsList(request)
-	case "tools/call":
-		return handleToolCall(request)
-	case "notifications/initialized":
-		return nil
-	default:
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32601,
-				Message: "Method not found",
+// toolContent converts an item of a tool result
+func toolContent(content tools.Content) ToolContent {
+	switch content.Type {
+	case "image":
+		return ToolContent{Type: content.Type, Data: content.Data, MimeType: content.MIMEType}
+	case "resource":
+		return ToolContent{
+			Type: content.Type,
+			Resource: &ResourceContents{
+				URI:      content.Resource.URI,
+				MimeType: content.Resource.MIMEType,
+				Blob:     &content.Resource.Blob,
 			},
 		}
+	default:
+		return textContent(content.Text)
 	}
 }
 
-func handleInitialize(request JSONRPCRequest) *JSONRPCResponse {
-	result := InitializeResult{
-		ProtocolVersion: "2024-11-05",
-		Capabilities: ServerCapabilities{
-			Tools: &ToolsCapability{},
-		},
-		ServerInfo: ServerInfo{
-			Name:    "filesystem-mcp-server",
-			Version: "1.0.0",
-		},
-	}
+// messageWriter encodes one JSON-RPC message per line, one writer at a time
+type messageWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
 
-	return &JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      request.ID,
-		Result:  result,
-	}
+func (w *messageWriter) write(message interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encoder.Encode(message)
 }
 
-func handleToolsList(request JSONRPCRequest) *JSONRPCResponse {
-	tools := []Tool{
-		{
-			Name:        "read_file",
-			Description: "Read the complete contents of a file from the filesystem",
-			InputSchema: InputSchema{
-				Type: "object",
-				Properties: map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the file to read",
-					},
-				},
-				Required: []string{"path"},
-			},
-		},
-		{
-			Name:        "write_file",
-			Description: "Write content to a file (overwrites existing content)",
-			InputSchema: InputSchema{
-				Type: "object",
-				Properties: map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the file to write",
-					},
-					"content": map[string]interface{}{
-						"type":        "string",
-						"description": "Content to write to the file",
-					},
-				},
-				Required: []string{"path", "content"},
-			},
-		},
-		{
-			Name:        "list_directory",
-			Description: "List the contents of a directory",
-			InputSchema: InputSchema{
-				Type: "object",
-				Properties: map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the directory to list",
-					},
-				},
-				Required: []string{"path"},
-			},
-		},
-		{
-			Name:        "create_directory",
-			Description: "Create a new directory",
-			InputSchema: InputSchema{
-				Type: "object",
-				Properties: map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the directory to create",
-					},
-				},
-				Required: []string{"path"},
-			},
-		},
-		{
-			Name:        "delete_file",
-			Description: "Delete a file or directory",
-			InputSchema: InputSchema{
-				Type: "object",
-				Properties: map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Path to the file or directory to delete",
-					},
-				},
-				Required: []string{"path"},
-			},
-		},
-	}
+var validator *filesystem.Validator
 
-	result := ToolsListResult{Tools: tools}
+// toolRegistry holds the tools offered to clients
+var toolRegistry *tools.Registry
 
-	return &JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      request.ID,
-		Result:  result,
-	}
-}
+// cfg holds the configuration from the command line
+var cfg config.Config
 
-func handleToolCall(request JSONRPCRequest) *JSONRPCResponse {
-	var params CallToolParams
-	paramBytes, _ := json.Marshal(request.Params)
-	json.Unmarshal(paramBytes, &params)
-
-	switch params.Name {
-	case "read_file":
-		return handleReadFile(request, params)
-	case "write_file":
-		return handleWriteFile(request, params)
-	case "list_directory":
-		return handleListDirectory(request, params)
-	case "create_directory":
-		return handleCreateDirectory(request, params)
-	case "delete_file":
-		return handleDeleteFile(request, params)
-	default:
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32602,
-				Message: "Unknown tool",
-			},
-		}
+func main() {
+	// Parse command line arguments
+	cfg.RegisterFlags(flag.CommandLine)
+	var transport, httpAddr string
+	flag.StringVar(&transport, "transport", "stdio", "Transport to serve MCP over: stdio or http (Streamable HTTP)")
+	flag.StringVar(&httpAddr, "addr", "127.0.0.1:8080", "Address to listen on with -transport=http")
+	var allowedHosts, allowedOrigins string
+	flag.StringVar(&allowedHosts, "allowed-hosts", "", "Comma separated host names besides localhost and the -addr host that HTTP clients may address the server by")
+	flag.StringVar(&allowedOrigins, "allowed-origins", "", "Comma separated browser origins besides loopback ones allowed to call the HTTP transport")
+	var authToken string
+	flag.StringVar(&authToken, "auth-token", "", "Bearer token HTTP clients must send; defaults to $MCP_AUTH_TOKEN, which keeps it out of process listings")
+	flag.Parse()
+	if err := cfg.Check(); err != nil {
+		log.Fatal(err)
 	}
-}
-
-func handleReadFile(request JSONRPCRequest, params CallToolParams) *JSONRPCResponse {
-	path, exists := params.Arguments["path"].(string)
-	if !exists {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32602,
-				Message: "Missing required parameter: path",
-			},
-		}
+	if authToken == "" {
+		authToken = os.Getenv("MCP_AUTH_TOKEN")
 	}
 
-	validPath, err := validator.ValidatePath(path)
+	var err error
+	validator, err = cfg.NewValidator()
 	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: err.Error(),
-			},
-		}
+		log.Fatal(err)
 	}
+	defer validator.Close()
 
-	content, err := ioutil.ReadFile(validPath)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Error reading file: %v", err),
-			},
-		}
+	toolRegistry = tools.NewFilesystem(validator, cfg.ToolOptions())
+
+	for _, root := range validator.Roots() {
+		log.Printf("MCP Filesystem Server starting with %s directory %s: %s", root.Mode, root.Name, root.Dir)
 	}
 
-	result := CallToolResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: string(content),
-			},
-		},
+	fileWatcher = cfg.StartWatcher(validator, handleWatchEvents)
+	if fileWatcher != nil {
+		defer fileWatcher.Close()
 	}
 
-	return &JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      request.ID,
-		Result:  result,
+	switch transport {
+	case "stdio":
+		serveStdio(cfg.Concurrency)
+	case "http":
+		serveHTTP(httpAddr, httpAccess{
+			Hosts:   splitList(allowedHosts),
+			Origins: splitList(allowedOrigins),
+			Token:   authToken,
+		})
+	default:
+		log.Fatalf("Unknown transport %q: use stdio or http", transport)
 	}
 }
 
-func handleWriteFile(request JSONRPCRequest, params CallToolParams) *JSONRPCResponse {
-	path, pathExists := params.Arguments["path"].(string)
-	content, contentExists := params.Arguments["content"].(string)
-
-	if !pathExists || !contentExists {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32602,
-				Message: "Missing required parameters: path and content",
-			},
+// serveStdio reads newline-delimited JSON-RPC messages from stdin and writes
+// responses and notifications to stdout for a single client. Up to
+// concurrency requests or batches run at once, so a slow call does not hold
+// up the ones behind it; responses carry the request's id and may be written
+// in any order.
+func serveStdio(concurrency int) {
+	reader := bufio.NewReader(os.Stdin)
+
+	// Responses come from the request workers while notifications come from
+	// the file watcher, so writes are serialized
+	output := &messageWriter{encoder: json.NewEncoder(os.Stdout)}
+	sess := newSession(func(notification JSONRPCNotification) {
+		if err := output.write(notification); err != nil {
+			log.Printf("Error encoding notification: %v", err)
+		}
+	})
+	defer sess.close()
+
+	respond := func(messages []startedMessage, batch bool) {
+		responses := runMessages(sess, messages)
+		var err error
+		switch {
+		case len(responses) == 0:
+			return
+		case batch:
+			err = output.write(responses)
+		default:
+			err = output.write(responses[0])
+		}
+		if err != nil {
+			log.Printf("Error encoding response: %v", err)
 		}
 	}
 
-	validPath, err := validator.ValidatePath(path)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: err.Error(),
-			},
+	// workers bounds the number of requests running at once. Requests wait
+	// for a slot in their own goroutine, so that the reader keeps reading
+	// cancellations and pings while all workers are busy.
+	workers := make(chan struct{}, concurrency)
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if messages, batch := parseMessages(line); len(messages) > 0 {
+			// Requests are registered before they wait for a worker so that
+			// a cancellation read next finds them
+			started := startMessages(context.Background(), sess, messages)
+
+			// Notifications and initialize change session state that later
+			// requests depend on, so they are handled in arrival order, as
+			// is ping, which must not wait behind busy workers
+			request := messages[0].request
+			if !batch && (request.ID == nil || request.Method == "initialize" || request.Method == "ping") {
+				respond(started, batch)
+			} else {
+				inFlight.Add(1)
+				go func() {
+					defer inFlight.Done()
+					workers <- struct{}{}
+					defer func() { <-workers }()
+					respond(started, batch)
+				}()
+			}
 		}
-	}
 
-	err = os.MkdirAll(filepath.Dir(validPath), 0755)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Error creating directory: %v", err),
-			},
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading request: %v", err)
+			}
+			break
 		}
 	}
+}
 
-	err = ioutil.WriteFile(validPath, []byte(content), 0644)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Error writing file: %v", err),
-			},
-		}
+// handleRequest handles one JSON-RPC message. ctx is the context the request
+// was registered with the session under by startMessages, so that
+// notifications/cancelled can abort it; a cancelled request gets no response.
+func handleRequest(ctx context.Context, sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	// Notifications are never answered, not even with an error
+	if request.ID == nil {
+		dispatchRequest(ctx, sess, request)
+		return nil
 	}
 
-	result := CallToolResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("Successfully wrote to file: %s", validPath),
-			},
-		},
+	// Requests cancelled while they waited to run are dropped unseen
+	if requestCancelled(ctx) {
+		return nil
 	}
 
-	return &JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      request.ID,
-		Result:  result,
+	response := dispatchRequest(ctx, sess, request)
+	if requestCancelled(ctx) {
+		return nil
 	}
+	return response
 }
 
-func handleListDirectory(request JSONRPCRequest, params CallToolParams) *JSONRPCResponse {
-	path, exists := params.Arguments["path"].(string)
-	if !exists {
+func dispatchRequest(ctx context.Context, sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	switch request.Method {
+	case "initialize":
+		return handleInitialize(sess, request)
+	case "ping":
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32602,
-				Message: "Missing required parameter: path",
-			},
+			Result:  EmptyResult{},
 		}
-	}
-
-	validPath, err := validator.ValidatePath(path)
-	if err != nil {
+	case "tools/list":
+		return handleToolsList(sess, request)
+	case "tools/call":
+		return handleToolCall(ctx, sess, request)
+	case "resources/list":
+		return handleResourcesList(ctx, request)
+	case "resources/templates/list":
+		return handleResourceTemplatesList(request)
+	case "resources/read":
+		return handleResourcesRead(ctx, request)
+	case "resources/subscribe":
+		return handleResourcesSubscribe(sess, request)
+	case "resources/unsubscribe":
+		return handleResourcesUnsubscribe(sess, request)
+	case "notifications/initialized":
+		sess.initialized.Store(true)
+		return nil
+	case "notifications/cancelled":
+		handleCancelled(sess, request)
+		return nil
+	default:
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
 			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: err.Error(),
+				Code:    -32601,
+				Message: "Method not found",
 			},
 		}
 	}
+}
 
-	files, err := ioutil.ReadDir(validPath)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Error reading directory: %v", err),
-			},
-		}
+func handleInitialize(sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	var params InitializeParams
+	if errResp := decodeParams(request, &params); errResp != nil {
+		return errResp
 	}
 
-	var fileList []string
-	for _, file := range files {
-		if file.IsDir() {
-			fileList = append(fileList, file.Name()+"/")
-		} else {
-			fileList = append(fileList, file.Name())
-		}
-	}
+	version := protocol.Negotiate(params.ProtocolVersion)
+	sess.setProtocolVersion(version)
 
-	result := CallToolResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("Directory contents:\n%s", strings.Join(fileList, "\n")),
+	watching := fileWatcher != nil
+	result := InitializeResult{
+		ProtocolVersion: version,
+		Capabilities: ServerCapabilities{
+			Tools: &ToolsCapability{},
+			Resources: &ResourcesCapability{
+				Subscribe:   &watching,
+				ListChanged: &watching,
 			},
 		},
+		ServerInfo: ServerInfo{
+			Name:    "filesystem-mcp-server",
+			Version: "1.0.0",
+		},
 	}
 
 	return &JSONRPCResponse{
@@ -484,52 +398,35 @@ func handleListDirectory(request JSONRPCRequest, params CallToolParams) *JSONRPC
 	}
 }
 
-func handleCreateDirectory(request JSONRPCRequest, params CallToolParams) *JSONRPCResponse {
-	path, exists := params.Arguments["path"].(string)
-	if !exists {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32602,
-				Message: "Missing required parameter: path",
-			},
+func handleToolsList(sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	var tools []Tool
+	for _, tool := range toolRegistry.Tools() {
+		t := Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: InputSchema(tool.InputSchema),
 		}
-	}
 
-	validPath, err := validator.ValidatePath(path)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: err.Error(),
-			},
+		// Leave out what the negotiated protocol version does not define
+		if sess.supports(protocol.ToolAnnotations) {
+			t.Annotations = &ToolAnnotations{
+				Title:           tool.Annotations.Title,
+				ReadOnlyHint:    tool.Annotations.ReadOnly,
+				DestructiveHint: tool.Annotations.Destructive,
+				IdempotentHint:  tool.Annotations.Idempotent,
+				OpenWorldHint:   tool.Annotations.OpenWorld,
+			}
 		}
-	}
-
-	err = os.MkdirAll(validPath, 0755)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Error creating directory: %v", err),
-			},
+		if sess.supports(protocol.StructuredOutput) && tool.OutputSchema != nil {
+			outputSchema := InputSchema(*tool.OutputSchema)
+			t.OutputSchema = &outputSchema
 		}
-	}
 
-	result := CallToolResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("Successfully created directory: %s", validPath),
-			},
-		},
+		tools = append(tools, t)
 	}
 
+	result := ToolsListResult{Tools: tools}
+
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      request.ID,
@@ -537,50 +434,39 @@ func handleCreateDirectory(request JSONRPCRequest, params CallToolParams) *JSONR
 	}
 }
 
-func handleDeleteFile(request JSONRPCRequest, params CallToolParams) *JSONRPCResponse {
-	path, exists := params.Arguments["path"].(string)
-	if !exists {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32602,
-				Message: "Missing required parameter: path",
-			},
-		}
+func handleToolCall(ctx context.Context, sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	var params CallToolParams
+	if errResp := decodeParams(request, &params); errResp != nil {
+		return errResp
 	}
 
-	validPath, err := validator.ValidatePath(path)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      request.ID,
-			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: err.Error(),
-			},
-		}
-	}
+	ctx = withProgress(ctx, sess, params.Meta)
 
-	err = os.RemoveAll(validPath)
+	toolResult, err := toolRegistry.Call(ctx, params.Name, params.Arguments)
 	if err != nil {
+		message := err.Error()
+		if errors.Is(err, tools.ErrUnknownTool) {
+			message = "Unknown tool"
+		}
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
 			Error: &JSONRPCError{
-				Code:    -32603,
-				Message: fmt.Sprintf("Error deleting file/directory: %v", err),
+				Code:    -32602,
+				Message: message,
 			},
 		}
 	}
 
-	result := CallToolResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("Successfully deleted: %s", validPath),
-			},
-		},
+	result := CallToolResult{}
+	for _, content := range toolResult.Content {
+		result.Content = append(result.Content, toolContent(content))
+	}
+	if toolResult.IsError {
+		result.IsError = &toolResult.IsError
+	}
+	if sess.supports(protocol.StructuredOutput) {
+		result.StructuredContent = toolResult.Structured
 	}
 
 	return &JSONRPCResponse{
@@ -588,4 +474,15 @@ func handleDeleteFile(request JSONRPCRequest, params CallToolParams) *JSONRPCRes
 		ID:      request.ID,
 		Result:  result,
 	}
-}
\ No newline at end of file
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
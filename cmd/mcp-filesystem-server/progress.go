@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+type RequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      int64       `json:"progress"`
+	Total         int64       `json:"total,omitempty"`
+}
+
+type notifierKey struct{}
+
+// withNotifier returns a context under which notifications about the
+// request, rather than the session, are delivered by notify
+func withNotifier(ctx context.Context, notify func(JSONRPCNotification)) context.Context {
+	return context.WithValue(ctx, notifierKey{}, notify)
+}
+
+// requestNotifier returns the function delivering notifications about the
+// request running under ctx, which defaults to the session's
+func requestNotifier(ctx context.Context, sess *session) func(JSONRPCNotification) {
+	if notify, ok := ctx.Value(notifierKey{}).(func(JSONRPCNotification)); ok {
+		return notify
+	}
+	return sess.notify
+}
+
+// withProgress returns a context under which long-running operations send
+// notifications/progress for the request's progress token, if it has one
+func withProgress(ctx context.Context, sess *session, meta *RequestMeta) context.Context {
+	if meta == nil || meta.ProgressToken == nil {
+		return ctx
+	}
+
+	notify := requestNotifier(ctx, sess)
+	return filesystem.WithProgress(ctx, func(progress, total int64) {
+		notify(JSONRPCNotification{
+			JSONRPC: "2.0",
+			Method:  "notifications/progress",
+			Params: ProgressParams{
+				ProgressToken: meta.ProgressToken,
+				Progress:      progress,
+				Total:         total,
+			},
+		})
+	})
+}
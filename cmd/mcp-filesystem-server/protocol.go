@@ -0,0 +1,20 @@
+package main
+
+import (
+	"mcp-filesystem-server/internal/protocol"
+)
+
+// setProtocolVersion records the version negotiated with the session's client
+func (s *session) setProtocolVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protocolVersion = version
+}
+
+// supports reports whether the session's negotiated version includes the
+// features introduced in the given revision
+func (s *session) supports(revision string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return protocol.Supports(s.protocolVersion, revision)
+}
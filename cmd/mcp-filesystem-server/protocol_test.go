@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"mcp-filesystem-server/internal/filesystem"
+	"mcp-filesystem-server/internal/tools"
+)
+
+// setupServer points the server's globals at a validator on a temporary directory
+func setupServer(t *testing.T) {
+	t.Helper()
+	v, err := filesystem.NewValidator(filesystem.RootSpec{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	validator = v
+	toolRegistry = tools.NewFilesystem(v, tools.Options{})
+}
+
+func TestProtocolDowngrade(t *testing.T) {
+	setupServer(t)
+
+	tests := []struct {
+		requested   string
+		negotiated  string
+		annotations bool
+		structured  bool
+	}{
+		{requested: "2024-11-05", negotiated: "2024-11-05"},
+		{requested: "2025-03-26", negotiated: "2025-03-26", annotations: true},
+		{requested: "2025-06-18", negotiated: "2025-06-18", annotations: true, structured: true},
+		{requested: "2099-01-01", negotiated: "2025-06-18", annotations: true, structured: true},
+		{requested: "1.0", negotiated: "2025-06-18", annotations: true, structured: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			sess := newSession(func(JSONRPCNotification) {})
+			defer sess.close()
+
+			response := handleInitialize(sess, JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "initialize", Params: map[string]interface{}{
+				"protocolVersion": tt.requested,
+				"capabilities":    map[string]interface{}{},
+				"clientInfo":      map[string]interface{}{"name": "test", "version": "1"},
+			}})
+			if response.Error != nil {
+				t.Fatalf("initialize failed: %v", response.Error.Message)
+			}
+			if got := response.Result.(InitializeResult).ProtocolVersion; got != tt.negotiated {
+				t.Errorf("negotiated %q, want %q", got, tt.negotiated)
+			}
+
+			response = handleToolsList(sess, JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "tools/list"})
+			for _, tool := range response.Result.(ToolsListResult).Tools {
+				if got := tool.Annotations != nil; got != tt.annotations {
+					t.Errorf("%s: annotations sent %v, want %v", tool.Name, got, tt.annotations)
+				}
+				if tool.OutputSchema != nil && !tt.structured {
+					t.Errorf("%s: output schema sent to a %s client", tool.Name, tt.negotiated)
+				}
+				if tool.Name == "list_allowed_directories" && tt.structured && tool.OutputSchema == nil {
+					t.Errorf("%s: output schema missing", tool.Name)
+				}
+			}
+
+			response = handleToolCall(context.Background(), sess, JSONRPCRequest{JSONRPC: "2.0", ID: 3, Method: "tools/call", Params: map[string]interface{}{
+				"name":      "list_allowed_directories",
+				"arguments": map[string]interface{}{},
+			}})
+			if got := response.Result.(CallToolResult).StructuredContent != nil; got != tt.structured {
+				t.Errorf("structured content sent %v, want %v", got, tt.structured)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// resourcePageSize is the number of resources returned per resources/list page
+const resourcePageSize = 100
+
+type ResourcesCapability struct {
+	Subscribe   *bool `json:"subscribe,omitempty"`
+	ListChanged *bool `json:"listChanged,omitempty"`
+}
+
+type Resource struct {
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType,omitempty"`
+	Size     int64  `json:"size"`
+}
+
+type ListResourcesParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type ListResourceTemplatesResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+type ResourceContents struct {
+	URI      string  `json:"uri"`
+	MimeType string  `json:"mimeType,omitempty"`
+	Text     *string `json:"text,omitempty"`
+	Blob     *string `json:"blob,omitempty"`
+}
+
+func handleResourcesList(ctx context.Context, request JSONRPCRequest) *JSONRPCResponse {
+	var params ListResourcesParams
+	if errResp := decodeParams(request, &params); errResp != nil {
+		return errResp
+	}
+
+	var ignore *filesystem.IgnoreFilter
+	if cfg.HonorIgnoreFiles {
+		ignore = validator.NewIgnoreFilter()
+	}
+
+	files, next, err := validator.ListFileResources(ctx, ignore, params.Cursor, resourcePageSize)
+	if errors.Is(err, filesystem.ErrInvalidCursor) {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid cursor",
+			},
+		}
+	}
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32603,
+				Message: fmt.Sprintf("Error listing resources: %v", err),
+			},
+		}
+	}
+
+	result := ListResourcesResult{Resources: []Resource{}, NextCursor: next}
+	for _, file := range files {
+		result.Resources = append(result.Resources, Resource{
+			URI:      file.URI,
+			Name:     file.Name,
+			MimeType: file.MIMEType,
+			Size:     file.Size,
+		})
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result:  result,
+	}
+}
+
+func handleResourceTemplatesList(request JSONRPCRequest) *JSONRPCResponse {
+	result := ListResourceTemplatesResult{
+		ResourceTemplates: []ResourceTemplate{
+			{
+				URITemplate: filesystem.FileURITemplate,
+				Name:        "file",
+				Description: "A file in one of the allowed directories, addressed by its absolute path",
+			},
+		},
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result:  result,
+	}
+}
+
+func handleResourcesRead(ctx context.Context, request JSONRPCRequest) *JSONRPCResponse {
+	var params ReadResourceParams
+	if errResp := decodeParams(request, &params); errResp != nil {
+		return errResp
+	}
+
+	if params.URI == "" {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Missing required parameter: uri",
+			},
+		}
+	}
+
+	contents, err := validator.ReadFileResource(ctx, params.URI, cfg.MaxReadBytes)
+	if err != nil {
+		code := -32603
+		if errors.Is(err, filesystem.ErrResourceNotFound) {
+			code = -32002
+		}
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    code,
+				Message: err.Error(),
+				Data:    map[string]interface{}{"uri": params.URI},
+			},
+		}
+	}
+
+	result := ReadResourceResult{
+		Contents: []ResourceContents{
+			{
+				URI:      contents.URI,
+				MimeType: contents.MIMEType,
+				Text:     contents.Text,
+				Blob:     contents.Blob,
+			},
+		},
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result:  result,
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mcp-filesystem-server/internal/protocol"
+)
+
+// session holds the state of one connected client. The stdio transport has a
+// single session for the life of the process; the HTTP transport creates one
+// per initialize request.
+type session struct {
+	id string
+
+	// initialized is set once the client sends notifications/initialized;
+	// no notifications are sent before that
+	initialized atomic.Bool
+
+	// notify delivers a server-initiated notification to the client
+	notify func(JSONRPCNotification)
+
+	// lastActive is the Unix time of the client's latest request, used to
+	// expire abandoned HTTP sessions
+	lastActive atomic.Int64
+
+	mu sync.Mutex
+
+	// protocolVersion is the MCP revision negotiated in initialize
+	protocolVersion string
+
+	// subscriptions maps the validated path of each subscribed resource to the URI the client used
+	subscriptions map[string]string
+
+	// inFlight maps the id of each running request, as encoded by requestKey,
+	// to the function that cancels it
+	inFlight map[string]context.CancelCauseFunc
+}
+
+// sessions tracks every live session so that file changes can be fanned out to them
+var sessions = struct {
+	sync.Mutex
+	byID map[string]*session
+}{byID: make(map[string]*session)}
+
+// newSession creates and registers a session with a random ID
+func newSession(notify func(JSONRPCNotification)) *session {
+	var buf [16]byte
+	rand.Read(buf[:])
+
+	s := &session{
+		id:              hex.EncodeToString(buf[:]),
+		notify:          notify,
+		protocolVersion: protocol.Versions[len(protocol.Versions)-1],
+		subscriptions:   make(map[string]string),
+		inFlight:        make(map[string]context.CancelCauseFunc),
+	}
+	s.touch()
+
+	sessions.Lock()
+	sessions.byID[s.id] = s
+	sessions.Unlock()
+
+	return s
+}
+
+// touch records client activity on the session
+func (s *session) touch() {
+	s.lastActive.Store(time.Now().Unix())
+}
+
+// idleSince returns when the client was last active
+func (s *session) idleSince() time.Time {
+	return time.Unix(s.lastActive.Load(), 0)
+}
+
+// close unregisters the session; it receives no further notifications
+func (s *session) close() {
+	sessions.Lock()
+	delete(sessions.byID, s.id)
+	sessions.Unlock()
+}
+
+// allSessions returns a snapshot of the live sessions
+func allSessions() []*session {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	list := make([]*session, 0, len(sessions.byID))
+	for _, s := range sessions.byID {
+		list = append(list, s)
+	}
+	return list
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+	"mcp-filesystem-server/internal/watcher"
+)
+
+// fileWatcher is nil when watching is disabled or unsupported, in which case
+// subscriptions and list_changed notifications are not offered
+var fileWatcher *watcher.Watcher
+
+type SubscribeParams struct {
+	URI string `json:"uri"`
+}
+
+type ResourceUpdatedParams struct {
+	URI string `json:"uri"`
+}
+
+type EmptyResult struct{}
+
+// handleWatchEvents notifies every initialized session about changed
+// resources it subscribed to and, when files were created or removed, about
+// the resource list changing
+func handleWatchEvents(events []watcher.Event) {
+	listChanged := false
+	for _, event := range events {
+		if event.Op&(watcher.Create|watcher.Remove) != 0 {
+			listChanged = true
+		}
+	}
+
+	for _, sess := range allSessions() {
+		if !sess.initialized.Load() {
+			continue
+		}
+
+		for _, uri := range sess.updatedResources(events) {
+			sess.notify(JSONRPCNotification{
+				JSONRPC: "2.0",
+				Method:  "notifications/resources/updated",
+				Params:  ResourceUpdatedParams{URI: uri},
+			})
+		}
+
+		if listChanged {
+			sess.notify(JSONRPCNotification{
+				JSONRPC: "2.0",
+				Method:  "notifications/resources/list_changed",
+			})
+		}
+	}
+}
+
+// updatedResources returns the URIs of the session's subscriptions affected by events
+func (s *session) updatedResources(events []watcher.Event) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updated []string
+	for _, event := range events {
+		for path, uri := range s.subscriptions {
+			// Removing or moving a directory also affects everything inside it
+			if path == event.Path || (event.Op&watcher.Remove != 0 && strings.HasPrefix(path, event.Path+string(filepath.Separator))) {
+				updated = append(updated, uri)
+			}
+		}
+	}
+	return updated
+}
+
+func handleResourcesSubscribe(sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	var params SubscribeParams
+	if errResp := decodeParams(request, &params); errResp != nil {
+		return errResp
+	}
+
+	if params.URI == "" {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Missing required parameter: uri",
+			},
+		}
+	}
+
+	if fileWatcher == nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32601,
+				Message: "Resource subscriptions are not available",
+			},
+		}
+	}
+
+	path, err := filesystem.PathFromURI(params.URI)
+	if err == nil {
+		path, err = validator.ValidatePath(path)
+	}
+	if err == nil {
+		err = validator.Authorize(filesystem.ResourcePolicyName, path)
+	}
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32603,
+				Message: err.Error(),
+			},
+		}
+	}
+
+	sess.mu.Lock()
+	sess.subscriptions[path] = params.URI
+	sess.mu.Unlock()
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result:  EmptyResult{},
+	}
+}
+
+func handleResourcesUnsubscribe(sess *session, request JSONRPCRequest) *JSONRPCResponse {
+	var params SubscribeParams
+	if errResp := decodeParams(request, &params); errResp != nil {
+		return errResp
+	}
+
+	path, err := filesystem.PathFromURI(params.URI)
+	if err == nil {
+		path, err = validator.ValidatePath(path)
+	}
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: err.Error(),
+			},
+		}
+	}
+
+	sess.mu.Lock()
+	delete(sess.subscriptions, path)
+	sess.mu.Unlock()
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result:  EmptyResult{},
+	}
+}
@@ -1,6 +1,6 @@
 module mcp-filesystem-server
 
-go 1.24.1
+go 1.25.0
 
 require github.com/mark3labs/mcp-go v0.41.0
 
@@ -0,0 +1,147 @@
+// Package config holds the command line setup both servers share: the
+// allowed directories and other common flags, the validator with its access
+// policy, and the file watcher.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"mcp-filesystem-server/internal/filesystem"
+	"mcp-filesystem-server/internal/tools"
+	"mcp-filesystem-server/internal/watcher"
+)
+
+// DefaultConcurrency is the default limit on stdio requests handled at once
+const DefaultConcurrency = 8
+
+// WatchDebounce is how long changes are collected before clients are notified
+const WatchDebounce = 200 * time.Millisecond
+
+// Config is the configuration shared by both servers
+type Config struct {
+	// Roots are the allowed directories
+	Roots filesystem.RootSpecs
+	// PolicyFile names a JSON access policy file
+	PolicyFile string
+	// HonorIgnoreFiles hides paths matched by .gitignore and .mcpignore
+	// files from resources/list and from list_directory unless a call sets
+	// includeIgnored
+	HonorIgnoreFiles bool
+	// Watch enables change notifications from watching the allowed directories
+	Watch bool
+	// Concurrency limits the stdio requests handled at once
+	Concurrency int
+	// MaxReadBytes caps the file content read_file and read_multiple_files
+	// return in one call and the size of files resources/read returns
+	MaxReadBytes int64
+}
+
+// RegisterFlags defines the shared flags on fs
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(&c.Roots, "dir", "Allowed directory as [name=]path[:ro|:rw]; may be repeated (default \".\")")
+	fs.StringVar(&c.PolicyFile, "policy", "", "JSON access policy file with allow/deny glob rules per tool")
+	fs.BoolVar(&c.HonorIgnoreFiles, "ignore-files", true, "Hide paths matched by .gitignore and .mcpignore files when listing directories and resources")
+	fs.BoolVar(&c.Watch, "watch", true, "Watch the allowed directories and notify clients when resources change")
+	fs.IntVar(&c.Concurrency, "concurrency", DefaultConcurrency, "Maximum number of stdio requests handled at once")
+	fs.Int64Var(&c.MaxReadBytes, "max-read-bytes", tools.DefaultMaxReadBytes, "Maximum bytes of file content read_file and read_multiple_files return in one call and resources/read returns at all")
+}
+
+// Check validates the parsed flags and fills in defaults
+func (c *Config) Check() error {
+	if len(c.Roots) == 0 {
+		c.Roots = filesystem.RootSpecs{{Dir: "."}}
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("invalid -concurrency %d: must be at least 1", c.Concurrency)
+	}
+	if c.MaxReadBytes < 1 {
+		return fmt.Errorf("invalid -max-read-bytes %d: must be at least 1", c.MaxReadBytes)
+	}
+	return nil
+}
+
+// NewValidator creates the read-write directories that do not exist yet and
+// returns a validator on the allowed directories with the access policy set
+func (c *Config) NewValidator() (*filesystem.Validator, error) {
+	for _, root := range c.Roots {
+		if root.Mode != filesystem.ReadWrite {
+			continue
+		}
+		if err := os.MkdirAll(root.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create base directory %s: %v", root.Dir, err)
+		}
+	}
+
+	// The directories must exist so that symlinks in their paths can be resolved
+	v, err := filesystem.NewValidator(c.Roots...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize allowed directories: %v", err)
+	}
+
+	if c.PolicyFile != "" {
+		policy, err := filesystem.LoadPolicy(c.PolicyFile)
+		if err != nil {
+			v.Close()
+			return nil, fmt.Errorf("failed to load access policy: %v", err)
+		}
+		v.SetPolicy(policy)
+	}
+
+	return v, nil
+}
+
+// ToolOptions returns the options of the filesystem tools
+func (c *Config) ToolOptions() tools.Options {
+	return tools.Options{
+		HonorIgnoreFiles: c.HonorIgnoreFiles,
+		MaxReadBytes:     c.MaxReadBytes,
+	}
+}
+
+// StartWatcher watches every allowed directory of v, skipping paths hidden
+// by ignore files, and calls handler with each batch of changes. It returns
+// nil when watching is disabled or unavailable.
+func (c *Config) StartWatcher(v *filesystem.Validator, handler func([]watcher.Event)) *watcher.Watcher {
+	if !c.Watch {
+		return nil
+	}
+
+	var dirs []string
+	for _, root := range v.Roots() {
+		dirs = append(dirs, root.Dir)
+	}
+
+	opts := watcher.Options{Debounce: WatchDebounce}
+	if c.HonorIgnoreFiles {
+		opts.Skip = ignoreSkip(v)
+	}
+
+	w, err := watcher.New(dirs, opts, handler, func(err error) {
+		log.Printf("File watcher: %v", err)
+	})
+	if err != nil {
+		log.Printf("Resource change notifications disabled: %v", err)
+		return nil
+	}
+	return w
+}
+
+// ignoreSkip returns a watcher Skip function hiding paths excluded by ignore
+// files. It keeps one ignore filter, so that a walk reads each ignore file
+// once, and starts a new one whenever it sees an ignore file, which may have
+// been created or changed. The watcher calls Skip from one goroutine at a time.
+func ignoreSkip(v *filesystem.Validator) func(path string, isDir bool) bool {
+	var filter *filesystem.IgnoreFilter
+	return func(path string, isDir bool) bool {
+		if filter == nil || slices.Contains(filesystem.IgnoreFileNames, filepath.Base(path)) {
+			filter = v.NewIgnoreFilter()
+		}
+		return filter.Ignored(path, isDir)
+	}
+}
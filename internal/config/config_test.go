@@ -0,0 +1,116 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// parse registers the shared flags on a fresh flag set and parses args
+func parse(t *testing.T, args ...string) *Config {
+	t.Helper()
+	var c Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+func TestCheck(t *testing.T) {
+	c := parse(t)
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Roots) != 1 || c.Roots[0].Dir != "." {
+		t.Errorf("default roots are %v, want the current directory", c.Roots)
+	}
+	if !c.HonorIgnoreFiles || !c.Watch || c.Concurrency != DefaultConcurrency {
+		t.Errorf("unexpected defaults %+v", c)
+	}
+
+	for _, args := range [][]string{{"-concurrency", "0"}, {"-max-read-bytes", "0"}} {
+		if err := parse(t, args...).Check(); err == nil {
+			t.Errorf("%v: no error", args)
+		}
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	base := t.TempDir()
+	rw := filepath.Join(base, "rw", "nested")
+	ro := filepath.Join(base, "ro")
+
+	// Missing read-write directories are created
+	c := parse(t, "-dir", rw)
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := c.NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+	if _, err := os.Stat(rw); err != nil {
+		t.Errorf("read-write directory not created: %v", err)
+	}
+
+	// Missing read-only directories are not
+	c = parse(t, "-dir", ro+":ro")
+	c.Check()
+	if v, err := c.NewValidator(); err == nil {
+		v.Close()
+		t.Error("no error for a missing read-only directory")
+	}
+
+	policy := filepath.Join(base, "policy.json")
+	if err := os.WriteFile(policy, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = parse(t, "-dir", rw, "-policy", policy)
+	c.Check()
+	if v, err := c.NewValidator(); err == nil {
+		v.Close()
+		t.Error("no error for an invalid policy")
+	}
+}
+
+func TestIgnoreSkip(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitignore := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(gitignore, []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := parse(t, "-dir", dir)
+	c.Check()
+	v, err := c.NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	skip := ignoreSkip(v)
+	log := filepath.Join(dir, "debug.log")
+	if !skip(log, false) {
+		t.Fatal("debug.log is not skipped")
+	}
+
+	// The rules read are kept until the ignore file itself is seen again
+	if err := os.WriteFile(gitignore, []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !skip(log, false) {
+		t.Error("debug.log is not skipped before the change to .gitignore is seen")
+	}
+	if skip(gitignore, false) {
+		t.Error(".gitignore is skipped")
+	}
+	if skip(log, false) {
+		t.Error("debug.log is still skipped after the change to .gitignore was seen")
+	}
+}
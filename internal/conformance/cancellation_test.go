@@ -0,0 +1,123 @@
+//go:build unix
+
+package conformance
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"mcp-filesystem-server/internal/protocol"
+)
+
+// TestCancellation cancels a running tool call and one waiting for a free
+// worker, and checks that the server stays responsive meanwhile
+func TestCancellation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping server sessions in short mode")
+	}
+
+	for _, srv := range servers {
+		t.Run(srv.name, func(t *testing.T) {
+			t.Parallel()
+
+			root, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Reading a named pipe blocks until something is written to it,
+			// which keeps the only worker busy for as long as the test needs
+			pipe := filepath.Join(root, "pipe")
+			if err := syscall.Mkfifo(pipe, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := startClient(srv.binary, "-dir", root, "-watch=false", "-concurrency", "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.close()
+			if _, err := c.initialize(protocol.Versions[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			running, err := c.request("tools/call", callParams("read_file", map[string]interface{}{"path": "pipe"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			writer := openWriter(t, pipe)
+			defer writer.Close()
+
+			queued, err := c.request("tools/call", callParams("list_allowed_directories", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range []int{running, queued} {
+				if err := c.notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": "test"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, err := c.call("ping", nil)
+			if err != nil || r == nil {
+				t.Fatalf("ping while the worker is busy: got %v, %v", r, err)
+			}
+
+			// Release the read, then give the server time to answer if it
+			// wrongly does
+			writer.WriteString("done")
+			writer.Close()
+			// mcp-go answers every tools/call, and it queues calls for its
+			// workers before the SDK server sees them, so a cancelled call
+			// that has not started yet still runs
+			want := map[int]check{running: nil, queued: nil}
+			if srv.name == "sdk" {
+				want[running] = isToolError("context canceled")
+				want[queued] = isToolText("Allowed directories:\n" + filepath.Base(root) + ": " + root + " (read-write)")
+			}
+			for _, id := range []int{running, queued} {
+				r, err := c.wait(id, time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				switch {
+				case want[id] == nil && r != nil:
+					t.Errorf("cancelled request %d: got %s, want no response", id, describe(r))
+				case want[id] != nil && r == nil:
+					t.Errorf("cancelled request %d: got no response", id)
+				case want[id] != nil:
+					if err := want[id](r); err != nil {
+						t.Errorf("cancelled request %d: %v", id, err)
+					}
+				}
+			}
+
+			r, err = c.call("tools/call", callParams("list_allowed_directories", nil))
+			if err != nil || r == nil {
+				t.Fatalf("call after cancellation: got %v, %v", r, err)
+			}
+			if err := isToolText("Allowed directories:\n" + filepath.Base(root) + ": " + root + " (read-write)")(r); err != nil {
+				t.Errorf("call after cancellation: %v", err)
+			}
+		})
+	}
+}
+
+// openWriter waits until the server has opened the named pipe for reading
+// and returns the pipe opened for writing
+func openWriter(t *testing.T, pipe string) *os.File {
+	deadline := time.Now().Add(responseTimeout)
+	for {
+		// Opening a pipe for writing without blocking fails until it has a reader
+		f, err := os.OpenFile(pipe, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			return f
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start reading %s: %v", pipe, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,251 @@
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// responseTimeout bounds the wait for the response to one request
+const responseTimeout = 10 * time.Second
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// response is a JSON-RPC response received from a server. A batch response
+// has only Batch set.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+
+	Batch []*response `json:"-"`
+}
+
+// client drives one server process over stdio
+type client struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+	nextID int
+
+	// messages receives every line the server writes to stdout
+	messages chan []byte
+
+	// early holds responses read while waiting for another request, by id
+	early map[string]*response
+}
+
+// startClient starts the server binary with the given arguments
+func startClient(binary string, args ...string) (*client, error) {
+	c := &client{
+		cmd:      exec.Command(binary, args...),
+		messages: make(chan []byte, 64),
+		early:    make(map[string]*response),
+	}
+	c.cmd.Stderr = &c.stderr
+
+	var err error
+	c.stdin, err = c.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting %s: %v", binary, err)
+	}
+
+	go func() {
+		defer close(c.messages)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				c.messages <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// initialize runs the initialize handshake asking for the given protocol
+// version and returns the version the server chose
+func (c *client) initialize(version string) (string, error) {
+	r, err := c.call("initialize", map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "mcp-conformance", "version": "1.0.0"},
+	})
+	if err == nil && r == nil {
+		err = fmt.Errorf("no response to initialize within %v", responseTimeout)
+	}
+	if err == nil && r.Error != nil {
+		err = fmt.Errorf("initialize failed: %s", r.Error.Message)
+	}
+	if err == nil {
+		err = c.notify("notifications/initialized", nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v\n%s", err, c.stderr.String())
+	}
+
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(r.Result, &result)
+	return result.ProtocolVersion, nil
+}
+
+// send writes one message to the server
+func (c *client) send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.sendRaw(string(data))
+}
+
+// sendRaw writes one line to the server as it is
+func (c *client) sendRaw(line string) error {
+	_, err := io.WriteString(c.stdin, line+"\n")
+	return err
+}
+
+// notify sends a notification
+func (c *client) notify(method string, params interface{}) error {
+	return c.send(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// request sends a request without waiting for its response and returns its id
+func (c *client) request(method string, params interface{}) (int, error) {
+	c.nextID++
+	id := c.nextID
+
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+	}
+	if params != nil {
+		request["params"] = params
+	}
+	if err := c.send(request); err != nil {
+		return 0, fmt.Errorf("error sending %s: %v", method, err)
+	}
+	return id, nil
+}
+
+// call sends a request and waits for its response
+func (c *client) call(method string, params interface{}) (*response, error) {
+	id, err := c.request(method, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.wait(id, responseTimeout)
+}
+
+// wait returns the response to the request with the given id, keeping
+// responses to other requests for later and skipping the notifications the
+// server sends in the meantime. It returns nil without an error when no
+// response arrived within timeout.
+func (c *client) wait(id int, timeout time.Duration) (*response, error) {
+	key, _ := json.Marshal(id)
+	if r, ok := c.early[string(key)]; ok {
+		delete(c.early, string(key))
+		return r, nil
+	}
+
+	deadline := time.After(timeout)
+	for {
+		r, err := c.next(deadline)
+		if r == nil || err != nil {
+			return nil, err
+		}
+		if bytes.Equal(r.ID, key) {
+			return r, nil
+		}
+		if r.Batch != nil || r.ID == nil {
+			return nil, fmt.Errorf("unexpected response %s while waiting for request %s", describe(r), key)
+		}
+		c.early[string(r.ID)] = r
+	}
+}
+
+// callRaw sends a line as it is and returns the next response, whatever
+// its id, which may be a batch response
+func (c *client) callRaw(line string) (*response, error) {
+	if err := c.sendRaw(line); err != nil {
+		return nil, fmt.Errorf("error sending %s: %v", line, err)
+	}
+	r, err := c.next(time.After(responseTimeout))
+	if r == nil && err == nil {
+		err = fmt.Errorf("no response to %s within %v", line, responseTimeout)
+	}
+	return r, err
+}
+
+// next returns the next response the server sends, skipping notifications,
+// or nil when deadline passes first
+func (c *client) next(deadline <-chan time.Time) (*response, error) {
+	for {
+		select {
+		case line, ok := <-c.messages:
+			if !ok {
+				return nil, fmt.Errorf("server exited\n%s", c.stderr.String())
+			}
+
+			var r response
+			if bytes.HasPrefix(bytes.TrimSpace(line), []byte("[")) {
+				if err := json.Unmarshal(line, &r.Batch); err != nil {
+					return nil, fmt.Errorf("invalid message from server: %s", line)
+				}
+				return &r, nil
+			}
+			if err := json.Unmarshal(line, &r); err != nil {
+				return nil, fmt.Errorf("invalid message from server: %s", line)
+			}
+			if r.Result == nil && r.Error == nil {
+				// A notification
+				continue
+			}
+			return &r, nil
+		case <-deadline:
+			return nil, nil
+		}
+	}
+}
+
+// close ends the session and waits for the server to exit
+func (c *client) close() {
+	c.stdin.Close()
+
+	done := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(responseTimeout):
+		c.cmd.Process.Kill()
+		<-done
+	}
+}
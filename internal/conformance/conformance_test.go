@@ -0,0 +1,444 @@
+package conformance
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"mcp-filesystem-server/internal/protocol"
+)
+
+// server is one implementation under test
+type server struct {
+	name   string
+	pkg    string
+	binary string
+}
+
+// servers are built by TestMain; the raw server comes first
+var servers = [2]server{
+	{name: "raw", pkg: "mcp-filesystem-server/cmd/mcp-filesystem-server"},
+	{name: "sdk", pkg: "mcp-filesystem-server/cmd/mcp-filesystem-server-mark3labs-mcp-go"},
+}
+
+// check inspects a server's response to a step
+type check func(r *response) error
+
+// step is one request of a scenario
+type step struct {
+	Method string
+	Params interface{}
+
+	// Raw, when set, is sent as it is instead of a request built from
+	// Method and Params, and the next response is checked whatever its id
+	Raw string
+
+	// Want checks the response of both servers
+	Want check
+
+	// SDK, when set, checks the SDK server's response instead of Want, and
+	// Drift explains why the servers are expected to differ; the responses
+	// are then not compared with each other
+	SDK   check
+	Drift string
+}
+
+// scenario is one session with a server
+type scenario struct {
+	Name string
+
+	// Files are created in the allowed directory before the server starts;
+	// names ending in a slash are directories
+	Files map[string]string
+
+	// Outside are files created in a directory next to the allowed one,
+	// which the server must not reach; the test fails if any of them is
+	// changed or removed by the end of the session
+	Outside map[string]string
+
+	// Links are symbolic links created in the allowed directory, from their
+	// name to their target as written
+	Links map[string]string
+
+	// ReadOnly allows the directory read-only
+	ReadOnly bool
+
+	// Policy is an access policy passed to the server with -policy
+	Policy string
+
+	// Args are extra command line arguments for the server, in which $ROOT
+	// and $OUTSIDE stand for the directories
+	Args []string
+
+	// ProtocolVersion is the version requested in initialize, the latest by
+	// default, and NegotiatedVersion the version the server must answer
+	// with when it is not the one requested
+	ProtocolVersion   string
+	NegotiatedVersion string
+
+	Steps []step
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		os.Exit(m.Run())
+	}
+
+	dir, err := os.MkdirTemp("", "mcp-conformance-bin-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for i := range servers {
+		servers[i].binary = filepath.Join(dir, filepath.Base(servers[i].pkg))
+		build := exec.Command("go", "build", "-o", servers[i].binary, servers[i].pkg)
+		if out, err := build.CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error building %s: %v\n%s", servers[i].pkg, err, out)
+			os.RemoveAll(dir)
+			os.Exit(1)
+		}
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// TestConformance runs every scenario against both servers: each response
+// is checked, and the servers must answer alike unless a step records why
+// they differ
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping server sessions in short mode")
+	}
+
+	for _, sc := range scenarios {
+		t.Run(sc.Name, func(t *testing.T) {
+			t.Parallel()
+			runScenario(t, sc)
+		})
+	}
+}
+
+// runScenario runs a scenario against both servers
+func runScenario(t *testing.T, sc scenario) {
+	var responses [2][]*response
+	for i, srv := range servers {
+		var err error
+		responses[i], err = runSession(t, sc, srv)
+		if err != nil {
+			t.Errorf("%s: %v", srv.name, err)
+			continue
+		}
+
+		for j, st := range sc.Steps {
+			want := st.Want
+			if srv.name == "sdk" && st.SDK != nil {
+				want = st.SDK
+			}
+			if want == nil {
+				continue
+			}
+			if err := want(responses[i][j]); err != nil {
+				t.Errorf("%s: step %d (%s): %v", srv.name, j+1, st.name(), err)
+			}
+		}
+	}
+	if t.Failed() {
+		return
+	}
+
+	for j, st := range sc.Steps {
+		if st.SDK != nil {
+			continue
+		}
+		if diff := compare(st.Method, responses[0][j], responses[1][j]); diff != "" {
+			t.Errorf("step %d (%s): servers differ: %s", j+1, st.name(), diff)
+		}
+	}
+}
+
+// name describes the step in failures
+func (st step) name() string {
+	if st.Raw != "" {
+		return st.Raw
+	}
+	return st.Method
+}
+
+// runSession runs the steps of a scenario against one server in a fresh
+// directory and returns the responses
+func runSession(t *testing.T, sc scenario, srv server) ([]*response, error) {
+	base := t.TempDir()
+
+	// Both servers see a directory of the same name, so that the root names
+	// in their results agree
+	root := filepath.Join(base, "work")
+	if err := createFiles(root, sc.Files); err != nil {
+		return nil, err
+	}
+	outside := filepath.Join(base, "outside")
+	if err := createFiles(outside, sc.Outside); err != nil {
+		return nil, err
+	}
+	for name, target := range sc.Links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			return nil, err
+		}
+	}
+	base, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return nil, err
+	}
+	root = filepath.Join(base, "work")
+	outside = filepath.Join(base, "outside")
+
+	places := placeholders{"$ROOT": root, "$OUTSIDE": outside}
+	dir := root
+	if sc.ReadOnly {
+		dir += ":ro"
+	}
+	args := []string{"-dir", dir, "-watch=false"}
+	for _, arg := range sc.Args {
+		args = append(args, strings.NewReplacer("$ROOT", root, "$OUTSIDE", outside).Replace(arg))
+	}
+	if sc.Policy != "" {
+		policy := filepath.Join(base, "policy.json")
+		if err := os.WriteFile(policy, []byte(sc.Policy), 0644); err != nil {
+			return nil, err
+		}
+		args = append(args, "-policy", policy)
+	}
+	c, err := startClient(srv.binary, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	version := sc.ProtocolVersion
+	if version == "" {
+		version = protocol.Versions[0]
+	}
+	negotiated, err := c.initialize(version)
+	if err != nil {
+		return nil, err
+	}
+	want := sc.NegotiatedVersion
+	if want == "" {
+		want = version
+	}
+	if negotiated != want {
+		return nil, fmt.Errorf("initialize with %s negotiated %s, want %s", version, negotiated, want)
+	}
+
+	responses := make([]*response, len(sc.Steps))
+	for i, st := range sc.Steps {
+		if st.Raw != "" {
+			responses[i], err = c.callRaw(places.expand(st.Raw))
+		} else {
+			var params interface{}
+			if st.Params != nil {
+				data, _ := json.Marshal(st.Params)
+				params = json.RawMessage(places.expand(string(data)))
+			}
+			responses[i], err = c.call(st.Method, params)
+			if responses[i] == nil && err == nil {
+				err = fmt.Errorf("no response within %v", responseTimeout)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1, st.name(), err)
+		}
+		places.normalize(responses[i])
+		if testing.Verbose() {
+			t.Logf("%s %s: %s", srv.name, st.name(), describe(responses[i]))
+		}
+	}
+
+	if err := checkFiles(outside, sc.Outside); err != nil {
+		return nil, fmt.Errorf("directory outside the allowed one changed: %v", err)
+	}
+	return responses, nil
+}
+
+// checkFiles reports an error unless dir holds exactly the given files
+func checkFiles(dir string, files map[string]string) error {
+	found := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			name += "/"
+		}
+		content, ok := files[name]
+		switch {
+		case !ok && !(d.IsDir() && hasPrefix(files, name)):
+			return fmt.Errorf("%s was created", name)
+		case !d.IsDir():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if string(data) != content {
+				return fmt.Errorf("%s now holds %q", name, data)
+			}
+		}
+		found[name] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for name := range files {
+		if !found[name] {
+			return fmt.Errorf("%s was removed", name)
+		}
+	}
+	return nil
+}
+
+// hasPrefix reports whether a name in files starts with prefix
+func hasPrefix(files map[string]string, prefix string) bool {
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// createFiles creates a directory with the given files in it
+func createFiles(root string, files map[string]string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholders maps the placeholders scenarios use, such as $ROOT, to the
+// directories they stand for in one session
+type placeholders map[string]string
+
+// expand replaces the placeholders in JSON text with their directories
+func (p placeholders) expand(text string) string {
+	for placeholder, dir := range p {
+		quoted, _ := json.Marshal(dir)
+		text = strings.ReplaceAll(text, placeholder, strings.Trim(string(quoted), `"`))
+	}
+	return text
+}
+
+// normalize replaces the directories in a response with their placeholders,
+// so that checks and comparisons do not depend on where the scenario ran
+func (p placeholders) normalize(r *response) {
+	for _, item := range r.Batch {
+		p.normalize(item)
+	}
+	for placeholder, dir := range p {
+		quoted, _ := json.Marshal(dir)
+		if r.Result != nil {
+			r.Result = json.RawMessage(strings.ReplaceAll(string(r.Result), strings.Trim(string(quoted), `"`), placeholder))
+		}
+		if r.Error != nil {
+			r.Error.Message = strings.ReplaceAll(r.Error.Message, dir, placeholder)
+		}
+	}
+}
+
+// compare describes how the responses of the two servers to one step
+// differ, or returns "" when they agree. Errors agree when their codes do,
+// as the SDK words its own error messages; results are compared as JSON.
+func compare(method string, raw, sdk *response) string {
+	switch {
+	case (raw.Batch == nil) != (sdk.Batch == nil) || len(raw.Batch) != len(sdk.Batch):
+		return fmt.Sprintf("raw %s, sdk %s", describe(raw), describe(sdk))
+	case raw.Batch != nil:
+		for i := range raw.Batch {
+			if diff := compare(method, raw.Batch[i], sdk.Batch[i]); diff != "" {
+				return fmt.Sprintf("batch item %d: %s", i+1, diff)
+			}
+		}
+		return ""
+	case (raw.Error == nil) != (sdk.Error == nil):
+		return fmt.Sprintf("raw %s, sdk %s", describe(raw), describe(sdk))
+	case raw.Error != nil:
+		if raw.Error.Code != sdk.Error.Code {
+			return fmt.Sprintf("raw error %d, sdk error %d", raw.Error.Code, sdk.Error.Code)
+		}
+		return ""
+	}
+
+	rawResult := normalizeResult(method, raw.Result)
+	sdkResult := normalizeResult(method, sdk.Result)
+	if !reflect.DeepEqual(rawResult, sdkResult) {
+		rawJSON, _ := json.Marshal(rawResult)
+		sdkJSON, _ := json.Marshal(sdkResult)
+		return fmt.Sprintf("\n        raw: %s\n        sdk: %s", rawJSON, sdkJSON)
+	}
+	return ""
+}
+
+func describe(r *response) string {
+	if r.Batch != nil {
+		var items []string
+		for _, item := range r.Batch {
+			items = append(items, describe(item))
+		}
+		return "batch [" + strings.Join(items, ", ") + "]"
+	}
+	if r.Error != nil {
+		return fmt.Sprintf("error %d %q", r.Error.Code, r.Error.Message)
+	}
+	return "result " + string(r.Result)
+}
+
+// normalizeResult decodes a result and removes the differences between the
+// servers that do not change its meaning
+func normalizeResult(method string, data json.RawMessage) interface{} {
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return string(data)
+	}
+
+	switch method {
+	case "tools/list":
+		// The SDK lists tools by name and always sends annotations
+		toolList, _ := result["tools"].([]interface{})
+		for _, t := range toolList {
+			tool, _ := t.(map[string]interface{})
+			if annotations, ok := tool["annotations"].(map[string]interface{}); ok && len(annotations) == 0 {
+				delete(tool, "annotations")
+			}
+		}
+		sort.Slice(toolList, func(i, j int) bool {
+			return fmt.Sprint(toolList[i].(map[string]interface{})["name"]) < fmt.Sprint(toolList[j].(map[string]interface{})["name"])
+		})
+	}
+	return result
+}
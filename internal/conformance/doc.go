@@ -0,0 +1,5 @@
+// Package conformance runs a corpus of MCP sessions against both server
+// implementations over stdio, checks each response and reports where the
+// servers disagree with each other. The scenarios live in its tests; run
+// them with go test ./internal/conformance.
+package conformance
@@ -0,0 +1,1212 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// argumentErrorDrift explains why the servers report invalid tool arguments differently
+const argumentErrorDrift = "mcp-go answers errors returned by tool handlers with -32603, so the SDK server reports invalid arguments as a failed result instead of -32602"
+
+// resourceErrorDrift explains why the servers report failed resource reads differently
+const resourceErrorDrift = "mcp-go answers every error of a resource handler with -32603, so the SDK server cannot report a missing resource with -32002"
+
+// resourceSizeDrift explains why the servers list resources differently
+const resourceSizeDrift = "mcp-go's Resource type has no size field, so the SDK server lists resources without their sizes"
+
+// batchDrift explains why the servers answer batches differently
+const batchDrift = "mcp-go does not support JSON-RPC batches and answers any array with a parse error"
+
+// scenarios is the corpus run against both servers
+var scenarios = []scenario{
+	{
+		Name: "ping",
+		Steps: []step{
+			{Method: "ping", Want: isResult(`{}`)},
+		},
+	},
+	{
+		Name: "unknown method",
+		Steps: []step{
+			{Method: "files/list", Want: isError(-32601)},
+		},
+	},
+	{
+		Name: "tools/list",
+		Steps: []step{
+			{Method: "tools/list", Want: hasTools(func(tools map[string]toolInfo) error {
+				for _, name := range []string{"read_file", "read_multiple_files", "write_file", "edit_file", "apply_patch", "move_file", "copy_file", "list_directory", "directory_tree", "search_files", "grep_files", "create_directory", "delete_file", "list_allowed_directories"} {
+					if _, ok := tools[name]; !ok {
+						return fmt.Errorf("tool %s missing", name)
+					}
+				}
+				if !tools["read_file"].Annotations["readOnlyHint"].(bool) {
+					return fmt.Errorf("read_file is not read-only")
+				}
+				if !tools["delete_file"].Annotations["destructiveHint"].(bool) {
+					return fmt.Errorf("delete_file is not destructive")
+				}
+				if tools["list_allowed_directories"].OutputSchema == nil {
+					return fmt.Errorf("list_allowed_directories has no output schema")
+				}
+				return nil
+			})},
+		},
+	},
+	{
+		Name:            "protocol 2024-11-05 hides newer tool features",
+		ProtocolVersion: "2024-11-05",
+		Steps: []step{
+			{Method: "tools/list", Want: hasTools(func(tools map[string]toolInfo) error {
+				for name, tool := range tools {
+					if len(tool.Annotations) > 0 {
+						return fmt.Errorf("tool %s has annotations", name)
+					}
+					if tool.OutputSchema != nil {
+						return fmt.Errorf("tool %s has an output schema", name)
+					}
+				}
+				return nil
+			})},
+			{
+				Method: "tools/call",
+				Params: callParams("list_allowed_directories", nil),
+				Want: hasToolResult(func(result toolResult) error {
+					if result.StructuredContent != nil {
+						return fmt.Errorf("unexpected structuredContent")
+					}
+					return nil
+				}),
+			},
+		},
+	},
+	{
+		Name: "file round trip",
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("create_directory", map[string]interface{}{"path": "docs"}),
+				Want:   isToolText("Successfully created directory: $ROOT/docs"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "docs/hello.txt", "content": "Hello, MCP!\n"}),
+				Want:   isToolText("Successfully wrote to file: $ROOT/docs/hello.txt"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "docs/hello.txt"}),
+				Want:   isToolText("Hello, MCP!\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": "docs"}),
+				Want:   isToolText("Directory contents:\nhello.txt"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "docs"}),
+				Want:   isToolText("Successfully deleted: $ROOT/docs"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "docs/hello.txt"}),
+				Want:   isToolError(""),
+			},
+		},
+	},
+	{
+		Name:  "paths outside the allowed directories",
+		Files: map[string]string{"inside.txt": "inside"},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "/etc/passwd"}),
+				Want:   isToolError(""),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "../inside.txt"}),
+				Want:   isToolError(""),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "../outside.txt", "content": "x"}),
+				Want:   isToolError(""),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "."}),
+				Want:   isToolError(""),
+			},
+		},
+	},
+	{
+		Name: "invalid tool calls",
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("format_disk", nil),
+				Want:   isError(-32602),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{}),
+				Want:   isErrorMessage(-32602, "Missing required parameter: path"),
+				SDK:    isToolError("Missing required parameter: path"),
+				Drift:  argumentErrorDrift,
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{}),
+				Want:   isErrorMessage(-32602, "Missing required parameters: path, content"),
+				SDK:    isToolError("Missing required parameters: path, content"),
+				Drift:  argumentErrorDrift,
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": 42}),
+				Want:   isErrorMessage(-32602, "Invalid parameter path: expected string"),
+				SDK:    isToolError("Invalid parameter path: expected string"),
+				Drift:  argumentErrorDrift,
+			},
+		},
+	},
+	{
+		Name:  "read_file ranges",
+		Args:  []string{"-max-read-bytes", "64"},
+		Files: map[string]string{"app.log": numberedLines(20)},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log", "offset": 3, "limit": 2}),
+				Want:   isToolText("line 3\nline 4\n\n[Showing lines 3-4; the file continues. Read more with offset=5.]"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log", "offset": 19, "lineNumbers": true}),
+				Want:   isToolText("    19\tline 19\n    20\tline 20\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log", "offset": 10}),
+				Want:   isToolText("line 10\nline 11\nline 12\nline 13\nline 14\nline 15\nline 16\nline 17\n\n[Showing lines 10-17; the file continues. Read more with offset=18.]"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log"}),
+				Want:   isToolText(numberedLines(9) + "l\n\n[Truncated: showing bytes 0-63 of 151. Read more with byteOffset=64, or page by lines with offset and limit.]"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log", "byteOffset": 7, "byteLength": 6}),
+				Want:   isToolText("line 2"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log", "offset": 30}),
+				Want:   isToolError("offset 30 is past the end of the file, which has 20 lines"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "app.log", "offset": 2, "byteOffset": 7}),
+				Want:   isErrorMessage(-32602, "cannot be combined"),
+				SDK:    isToolError("cannot be combined"),
+				Drift:  argumentErrorDrift,
+			},
+		},
+	},
+	{
+		Name: "binary files",
+		Files: map[string]string{
+			"pixel.png":  "\x89PNG\r\n\x1a\n\x00\x00",
+			"data.bin":   "\x00\x01\x02",
+			"latin1.txt": "caf\xe9\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "pixel.png"}),
+				Want:   isResult(`{"content":[{"type":"image","data":"iVBORw0KGgoAAA==","mimeType":"image/png"}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "data.bin"}),
+				Want:   isResult(`{"content":[{"type":"resource","resource":{"uri":"file://$ROOT/data.bin","mimeType":"application/octet-stream","blob":"AAEC"}}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "latin1.txt"}),
+				Want:   isResult(`{"content":[{"type":"resource","resource":{"uri":"file://$ROOT/latin1.txt","mimeType":"text/plain","blob":"Y2Fm6Qo="}}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "latin1.txt", "encoding": "text"}),
+				Want:   isToolText("caf\uFFFD\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "pixel.png", "byteLength": 4}),
+				Want:   isResult(`{"content":[{"type":"resource","resource":{"uri":"file://$ROOT/pixel.png","mimeType":"image/png","blob":"iVBORw=="}}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "pixel.png", "lineNumbers": true}),
+				Want:   isToolError("is not a text file"),
+			},
+		},
+	},
+	{
+		Name: "read_multiple_files",
+		Files: map[string]string{
+			"a.txt":     "alpha\n",
+			"b.txt":     "beta\n",
+			"pixel.png": "\x89PNG\r\n\x1a\n\x00\x00",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{"a.txt", "missing.txt", "pixel.png", "b.txt"}}),
+				Want: isResult(`{"content":[` +
+					`{"type":"text","text":"a.txt:\nalpha\n"},` +
+					`{"type":"text","text":"missing.txt: Error reading file: openat missing.txt: no such file or directory"},` +
+					`{"type":"text","text":"pixel.png:"},` +
+					`{"type":"image","data":"iVBORw0KGgoAAA==","mimeType":"image/png"},` +
+					`{"type":"text","text":"b.txt:\nbeta\n"}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{}}),
+				Want:   isErrorMessage(-32602, "must list at least one path"),
+				SDK:    isToolError("must list at least one path"),
+				Drift:  argumentErrorDrift,
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": make([]string, 101)}),
+				Want:   isErrorMessage(-32602, "must list at most 100 paths"),
+				SDK:    isToolError("must list at most 100 paths"),
+				Drift:  argumentErrorDrift,
+			},
+		},
+	},
+	{
+		Name: "edit_file",
+		Files: map[string]string{
+			"main.go": "package main\n\nfunc main() {\n\tif ready {\n\t\tstart()\n\t}\n\tstop()\n\tstop()\n}\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("edit_file", map[string]interface{}{
+					"path":   "main.go",
+					"dryRun": true,
+					"edits":  []map[string]string{{"oldText": "start()", "newText": "run()"}},
+				}),
+				Want: isToolText("Dry run, nothing was written. The edits would change $ROOT/main.go:\n" +
+					"--- main.go\n+++ main.go\n@@ -2,7 +2,7 @@\n \n func main() {\n \tif ready {\n-\t\tstart()\n+\t\trun()\n \t}\n \tstop()\n \tstop()\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("edit_file", map[string]interface{}{
+					"path": "main.go",
+					"edits": []map[string]string{
+						{"oldText": "start()", "newText": "run()"},
+						{"oldText": "stop()", "newText": "halt()"},
+					},
+				}),
+				Want: isToolError("Edit 2 of 2 failed, nothing was written: oldText matches 2 places"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("edit_file", map[string]interface{}{
+					"path":             "main.go",
+					"ignoreWhitespace": true,
+					"edits":            []map[string]string{{"oldText": "if ready {\n    start()\n}", "newText": "if ready {\n    run()\n}"}},
+				}),
+				Want: isToolText("Successfully edited $ROOT/main.go:\n" +
+					"--- main.go\n+++ main.go\n@@ -2,7 +2,7 @@\n \n func main() {\n \tif ready {\n-\t\tstart()\n+\t\trun()\n \t}\n \tstop()\n \tstop()\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "main.go"}),
+				Want:   isToolText("package main\n\nfunc main() {\n\tif ready {\n\t\trun()\n\t}\n\tstop()\n\tstop()\n}\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("edit_file", map[string]interface{}{
+					"path":  "main.go",
+					"edits": []map[string]string{{"oldText": "start()", "newText": "run()"}},
+				}),
+				Want: isToolError("oldText was not found"),
+			},
+		},
+	},
+	{
+		Name: "apply_patch",
+		Files: map[string]string{
+			"notes.txt": numberedLines(10),
+			"old.txt":   "to be renamed\n",
+			"gone.txt":  "to be deleted\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("apply_patch", map[string]interface{}{"patch": "" +
+					"--- a/notes.txt\n+++ b/notes.txt\n@@ -1,3 +1,3 @@\n line 1\n-line 2\n+line two\n line 3\n" +
+					"--- a/notes.txt\n+++ b/notes.txt\n@@ -9,2 +9,2 @@\n line 9\n-line ten\n+line 10\n"}),
+				Want: isToolError("Patch not applied, no files were changed: the patch changes notes.txt more than once"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("apply_patch", map[string]interface{}{"patch": "" +
+					"--- a/notes.txt\n+++ b/notes.txt\n@@ -1,3 +1,3 @@\n line 1\n-line 2\n+line two\n line 3\n" +
+					"@@ -9,2 +9,2 @@\n line 9\n-line ten\n+line 10\n" +
+					"--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+created\n"}),
+				Want: isToolError("Patch not applied, no files were changed: 1 of 3 hunks failed.\n\n" +
+					"notes.txt: hunk 2 (@@ -9,2 +9,2 @@): the hunk expects these lines at line 9:\n  line 9\n  line ten\nbut the file has:\n  line 9\n  line 10"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "new.txt"}),
+				Want:   isToolError("no such file"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("apply_patch", map[string]interface{}{"patch": "" +
+					"diff --git a/notes.txt b/notes.txt\n--- a/notes.txt\n+++ b/notes.txt\n@@ -3,3 +3,3 @@\n line 1\n-line 2\n+line two\n line 3\n" +
+					"diff --git a/old.txt b/renamed.txt\nsimilarity index 100%\nrename from old.txt\nrename to renamed.txt\n" +
+					"diff --git a/gone.txt b/gone.txt\ndeleted file mode 100644\n--- a/gone.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-to be deleted\n" +
+					"diff --git a/new.txt b/new.txt\nnew file mode 100644\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+created\n"}),
+				Want: isToolText("Applied patch:\n  patched notes.txt\n    hunk 1 applied with offset -2 lines\n" +
+					"  renamed old.txt to renamed.txt\n  deleted gone.txt\n  created new.txt\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{"new.txt", "renamed.txt", "old.txt", "gone.txt"}}),
+				Want: isResult(`{"content":[` +
+					`{"type":"text","text":"new.txt:\ncreated\n"},` +
+					`{"type":"text","text":"renamed.txt:\nto be renamed\n"},` +
+					`{"type":"text","text":"old.txt: Error reading file: openat old.txt: no such file or directory"},` +
+					`{"type":"text","text":"gone.txt: Error reading file: openat gone.txt: no such file or directory"}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "notes.txt", "limit": 3}),
+				Want:   isToolText("line 1\nline two\nline 3\n\n[Showing lines 1-3; the file continues. Read more with offset=4.]"),
+			},
+		},
+	},
+	{
+		Name:    "apply_patch on symlinks",
+		Files:   map[string]string{"real.txt": "real\n"},
+		Outside: map[string]string{"secret.txt": "top secret\n"},
+		Links: map[string]string{
+			"link.txt":    "real.txt",
+			"alias.txt":   "real.txt",
+			"secret-link": "../outside/secret.txt",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("apply_patch", map[string]interface{}{"patch": "" +
+					"diff --git a/link.txt b/link.txt\ndeleted file mode 120000\n--- a/link.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-real.txt\n\\ No newline at end of file\n" +
+					"diff --git a/alias.txt b/renamed-link.txt\nsimilarity index 100%\nrename from alias.txt\nrename to renamed-link.txt\n" +
+					"diff --git a/secret-link b/secret-link\ndeleted file mode 120000\n--- a/secret-link\n+++ /dev/null\n@@ -1 +0,0 @@\n-../outside/secret.txt\n\\ No newline at end of file\n"}),
+				Want: isToolText("Applied patch:\n  deleted link.txt\n  renamed alias.txt to renamed-link.txt\n  deleted secret-link\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("apply_patch", map[string]interface{}{"patch": "" +
+					"diff --git a/renamed-link.txt b/other.txt\nsimilarity index 50%\nrename from renamed-link.txt\nrename to other.txt\n" +
+					"--- a/renamed-link.txt\n+++ b/other.txt\n@@ -1 +1 @@\n-real.txt\n\\ No newline at end of file\n+../outside/secret.txt\n\\ No newline at end of file\n"}),
+				Want: isToolError("Patch not applied, no files were changed: renamed-link.txt is a symlink, which the patch can rename or delete but not point elsewhere"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{"real.txt", "renamed-link.txt", "link.txt"}}),
+				Want: isResult(`{"content":[` +
+					`{"type":"text","text":"real.txt:\nreal\n"},` +
+					`{"type":"text","text":"renamed-link.txt:\nreal\n"},` +
+					`{"type":"text","text":"link.txt: Error reading file: openat link.txt: no such file or directory"}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": "."}),
+				Want:   isToolText("Directory contents:\nreal.txt\nrenamed-link.txt"),
+			},
+		},
+	},
+	{
+		Name: "move_file and copy_file",
+		Files: map[string]string{
+			"src/a.txt":     "a",
+			"src/sub/b.txt": "b",
+			"dst/a.txt":     "kept",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("copy_file", map[string]interface{}{"source": "src", "destination": "dst"}),
+				Want:   isToolError("$ROOT/dst already exists; set overwrite to replace or skip to copy anyway"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("copy_file", map[string]interface{}{"source": "src", "destination": "src/sub/copy"}),
+				Want:   isToolError("Cannot copy $ROOT/src into itself"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("copy_file", map[string]interface{}{"source": "src", "destination": "dst", "overwrite": "skip"}),
+				Want: isToolText("Successfully copied $ROOT/src to $ROOT/dst\n" +
+					"Skipped 1 path(s) that already exist at the destination:\n$ROOT/dst/a.txt"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("move_file", map[string]interface{}{"source": "src/a.txt", "destination": "dst/a.txt", "overwrite": "replace"}),
+				Want:   isToolText("Successfully moved $ROOT/src/a.txt to $ROOT/dst/a.txt"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("move_file", map[string]interface{}{"source": "src", "destination": "archive/src"}),
+				Want:   isToolText("Successfully moved $ROOT/src to $ROOT/archive/src"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{"dst/a.txt", "dst/sub/b.txt", "archive/src/sub/b.txt"}}),
+				Want: isResult(`{"content":[` +
+					`{"type":"text","text":"dst/a.txt:\na"},` +
+					`{"type":"text","text":"dst/sub/b.txt:\nb"},` +
+					`{"type":"text","text":"archive/src/sub/b.txt:\nb"}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": "."}),
+				Want:   isToolText("Directory contents:\narchive/\ndst/"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("move_file", map[string]interface{}{"source": "dst", "destination": "archive", "overwrite": "always"}),
+				Want:   isErrorMessage(-32602, "must be never, replace or skip"),
+				SDK:    isToolError("must be never, replace or skip"),
+				Drift:  argumentErrorDrift,
+			},
+		},
+	},
+	{
+		Name: "search_files",
+		Files: map[string]string{
+			".gitignore":          "node_modules/\n",
+			"main.go":             "package main\n",
+			"cmd/tool/main.go":    "package main\n",
+			"internal/a/a.go":     "package a\n",
+			"internal/a/a.md":     "",
+			"node_modules/dep.go": "",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("search_files", map[string]interface{}{"path": ".", "pattern": "**/*.go"}),
+				Want:   isToolText("cmd/tool/main.go\ninternal/a/a.go\nmain.go"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("search_files", map[string]interface{}{"path": ".", "pattern": "**/*.go", "excludePatterns": []string{"cmd"}, "includeIgnored": true}),
+				Want:   isToolText("internal/a/a.go\nmain.go\nnode_modules/dep.go"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("search_files", map[string]interface{}{"path": ".", "pattern": "**", "maxDepth": 1, "maxResults": 3}),
+				Want:   isToolText(".gitignore\ncmd/\ninternal/\n\n[Showing the first 3 matches; narrow the pattern or raise maxResults to see more.]"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("search_files", map[string]interface{}{"path": "internal", "pattern": "*.rs"}),
+				Want:   isToolText("No paths under $ROOT/internal match *.rs"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("search_files", map[string]interface{}{"path": ".", "pattern": "[a-"}),
+				Want:   isErrorMessage(-32602, "Invalid parameter pattern"),
+				SDK:    isToolError("Invalid parameter pattern"),
+				Drift:  argumentErrorDrift,
+			},
+		},
+	},
+	{
+		Name: "grep_files",
+		Files: map[string]string{
+			".gitignore":   "build/\n",
+			"src/a.go":     "package a\n\nfunc Foo() {\n\tfoo()\n\tbar()\n}\n\nfunc baz() {\n\tFOO()\n}\n",
+			"notes.txt":    "foo\nfoo\nfoo\n",
+			"data.bin":     "foo\x00\n",
+			"build/gen.go": "foo\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{
+					"pattern":         "foo",
+					"caseInsensitive": true,
+					"includePatterns": []string{"*.go"},
+					"contextBefore":   1,
+					"contextAfter":    1,
+				}),
+				Want: isToolText("Found 3 matching line(s) in 1 file(s):\n\nsrc/a.go\n" +
+					"2-\n3:func Foo() {\n4:\tfoo()\n5-\tbar()\n--\n8-func baz() {\n9:\tFOO()\n10-}"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{"pattern": "foo(", "literal": true, "maxMatchesPerFile": 1}),
+				Want:   isToolText("Found 1 matching line(s) in 1 file(s):\n\nsrc/a.go\n4:\tfoo()"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{"pattern": "^foo$", "maxResults": 2}),
+				Want: isToolText("Found 2 matching line(s) in 1 file(s):\n\nnotes.txt\n1:foo\n2:foo\n\n" +
+					"[Stopped after 2 matches; narrow the search or raise maxResults to see more.]"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{"pattern": "^foo$", "path": "src"}),
+				Want:   isToolText("No matches for ^foo$ in $ROOT/src"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{"pattern": "foo("}),
+				Want:   isErrorMessage(-32602, "Invalid parameter pattern"),
+				SDK:    isToolError("Invalid parameter pattern"),
+				Drift:  argumentErrorDrift,
+			},
+		},
+	},
+	{
+		Name: "directory_tree",
+		Files: map[string]string{
+			".gitignore":        "node_modules/\n",
+			"go.mod":            "module x\n",
+			"cmd/app/main.go":   "package main\n",
+			"internal/a.go":     "",
+			"internal/b.go":     "",
+			"internal/c.go":     "",
+			"node_modules/x.js": "",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("directory_tree", map[string]interface{}{"path": ".", "maxDepth": 2, "maxEntries": 2}),
+				Want: hasToolResult(func(result toolResult) error {
+					var tree bytes.Buffer
+					if err := json.Compact(&tree, result.StructuredContent); err != nil {
+						return fmt.Errorf("invalid structuredContent: %v", err)
+					}
+					want := `{"name":"work","type":"directory","children":[` +
+						`{"name":".gitignore","type":"file","size":14},` +
+						`{"name":"cmd","type":"directory","children":[{"name":"app","type":"directory","truncated":true}]}],` +
+						`"omitted":2}`
+					if tree.String() != want {
+						return fmt.Errorf("got tree %s, want %s", tree.String(), want)
+					}
+					return nil
+				}),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("directory_tree", map[string]interface{}{"path": "internal", "maxDepth": 1}),
+				Want: isToolText("{\n  \"name\": \"internal\",\n  \"type\": \"directory\",\n  \"children\": [\n" +
+					"    {\n      \"name\": \"a.go\",\n      \"type\": \"file\",\n      \"size\": 0\n    },\n" +
+					"    {\n      \"name\": \"b.go\",\n      \"type\": \"file\",\n      \"size\": 0\n    },\n" +
+					"    {\n      \"name\": \"c.go\",\n      \"type\": \"file\",\n      \"size\": 0\n    }\n  ]\n}"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("directory_tree", map[string]interface{}{"path": "go.mod"}),
+				Want:   isToolError("$ROOT/go.mod is not a directory"),
+			},
+		},
+	},
+	{
+		Name: "ignore files",
+		Files: map[string]string{
+			".gitignore":          "node_modules/\n",
+			"node_modules/dep.js": "",
+			"main.go":             "package main\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": "."}),
+				Want:   isToolText("Directory contents:\n.gitignore\nmain.go"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": ".", "includeIgnored": true}),
+				Want:   isToolText("Directory contents:\n.gitignore\nmain.go\nnode_modules/"),
+			},
+		},
+	},
+	{
+		Name:     "read-only directory",
+		ReadOnly: true,
+		Files:    map[string]string{"notes.txt": "keep me"},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "notes.txt", "content": "overwritten"}),
+				Want:   isToolError("read-only"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "notes.txt"}),
+				Want:   isToolError("read-only"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "notes.txt"}),
+				Want:   isToolText("keep me"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_allowed_directories", nil),
+				Want: hasToolResult(func(result toolResult) error {
+					var structured struct {
+						Directories []struct {
+							Name string `json:"name"`
+							Path string `json:"path"`
+							Mode string `json:"mode"`
+						} `json:"directories"`
+					}
+					if err := json.Unmarshal(result.StructuredContent, &structured); err != nil {
+						return fmt.Errorf("invalid structuredContent: %v", err)
+					}
+					if len(structured.Directories) != 1 {
+						return fmt.Errorf("got %d directories, want 1", len(structured.Directories))
+					}
+					if dir := structured.Directories[0]; dir.Name != "work" || dir.Path != "$ROOT" || dir.Mode != "read-only" {
+						return fmt.Errorf("unexpected directory %+v", dir)
+					}
+					return nil
+				}),
+			},
+		},
+	},
+	{
+		Name:  "symlinks out of the allowed directory",
+		Files: map[string]string{"notes.txt": "inside\n"},
+		Outside: map[string]string{
+			"secret.txt":  "top secret\n",
+			"dir/":        "",
+			"dir/key.pem": "top secret\n",
+		},
+		Links: map[string]string{
+			"escape":      "../outside",
+			"secret-link": "../outside/secret.txt",
+			"chain":       "secret-link",
+			"dangling":    "../outside/new.txt",
+			"inner":       "notes.txt",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "secret-link"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "chain"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "escape/dir/key.pem"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "inner"}),
+				Want:   isToolText("inside\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": "escape"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "secret-link", "content": "overwritten"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "dangling", "content": "created"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("edit_file", map[string]interface{}{
+					"path":  "escape/secret.txt",
+					"edits": []map[string]string{{"oldText": "top", "newText": "no"}},
+				}),
+				Want: isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("copy_file", map[string]interface{}{"source": "secret-link", "destination": "copy.txt"}),
+				Want:   isToolText("Successfully copied $ROOT/secret-link to $ROOT/copy.txt"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "copy.txt"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("move_file", map[string]interface{}{"source": "dangling", "destination": "moved-link"}),
+				Want:   isToolText("Successfully moved $ROOT/dangling to $ROOT/moved-link"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "moved-link", "content": "created"}),
+				Want:   isToolError("path outside allowed directories"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{"pattern": "secret"}),
+				Want:   isToolText("No matches for secret in $ROOT"),
+			},
+			{
+				Method: "resources/read",
+				Params: map[string]interface{}{"uri": "file://$OUTSIDE/secret.txt"},
+				Want:   isErrorMessage(-32603, "path outside allowed directories"),
+			},
+			{
+				Method: "resources/list",
+				Want:   isResourceList("work/notes.txt"),
+				SDK:    isResourceList("work/notes.txt"),
+				Drift:  resourceSizeDrift,
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "secret-link"}),
+				Want:   isToolText("Successfully deleted: $ROOT/secret-link"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "escape"}),
+				Want:   isToolText("Successfully deleted: $ROOT/escape"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("list_directory", map[string]interface{}{"path": "."}),
+				Want:   isToolText("Directory contents:\nchain\ncopy.txt\ninner\nmoved-link\nnotes.txt"),
+			},
+		},
+	},
+	{
+		Name: "nested read-only directory",
+		Args: []string{"-dir", "$ROOT/lib/vendor:ro"},
+		Files: map[string]string{
+			"lib/util.go":       "package lib\n",
+			"lib/vendor/dep.go": "package dep\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("move_file", map[string]interface{}{"source": "lib", "destination": "pkg"}),
+				Want:   isToolError("Cannot move $ROOT/lib, which contains allowed directory $ROOT/lib/vendor"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "lib"}),
+				Want:   isToolError("refusing to remove $ROOT/lib, which contains allowed directory $ROOT/lib/vendor"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("move_file", map[string]interface{}{"source": "lib/vendor/dep.go", "destination": "dep.go"}),
+				Want:   isToolError("read-only directory $ROOT/lib/vendor"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("copy_file", map[string]interface{}{"source": "lib", "destination": "pkg"}),
+				Want:   isToolText("Successfully copied $ROOT/lib to $ROOT/pkg"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{"lib/vendor/dep.go", "pkg/vendor/dep.go"}}),
+				Want: isResult(`{"content":[` +
+					`{"type":"text","text":"lib/vendor/dep.go:\npackage dep\n"},` +
+					`{"type":"text","text":"pkg/vendor/dep.go:\npackage dep\n"}]}`),
+			},
+		},
+	},
+	{
+		Name: "access policy denials",
+		Policy: `{"default": "allow", "rules": [
+			{"name": "secrets", "action": "deny", "paths": ["**/.env"]},
+			{"name": "build-output", "action": "deny", "tools": ["write_file", "delete_file"], "paths": ["dist/**"]}
+		]}`,
+		Files: map[string]string{
+			".env":        "TOKEN=secret\n",
+			"dist/app.js": "built\n",
+			"main.go":     "package main\n",
+		},
+		Steps: []step{
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": ".env"}),
+				Want:   isToolError(`denied by policy rule "secrets"`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": ".env", "content": "TOKEN=other\n"}),
+				Want:   isToolError(`denied by policy rule "secrets"`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_file", map[string]interface{}{"path": "dist/app.js"}),
+				Want:   isToolText("built\n"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("write_file", map[string]interface{}{"path": "dist/app.js", "content": "changed\n"}),
+				Want:   isToolError(`denied by policy rule "build-output"`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("delete_file", map[string]interface{}{"path": "dist"}),
+				Want:   isToolError(`denied by policy rule "build-output"`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("read_multiple_files", map[string]interface{}{"paths": []string{".env", "main.go"}}),
+				Want: isResult(`{"content":[` +
+					`{"type":"text","text":".env: access denied: read_multiple_files on $ROOT/.env is denied by policy rule \"secrets\" (**/.env)"},` +
+					`{"type":"text","text":"main.go:\npackage main\n"}]}`),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("grep_files", map[string]interface{}{"pattern": "TOKEN"}),
+				Want:   isToolText("No matches for TOKEN in $ROOT"),
+			},
+			{
+				Method: "tools/call",
+				Params: callParams("search_files", map[string]interface{}{"path": ".", "pattern": "**"}),
+				Want:   isToolText("dist/\ndist/app.js\nmain.go"),
+			},
+			{
+				Method: "resources/list",
+				Want:   isResourceList("work/dist/app.js", "work/main.go"),
+				SDK:    isResourceList("work/dist/app.js", "work/main.go"),
+				Drift:  resourceSizeDrift,
+			},
+			{
+				Method: "resources/read",
+				Params: map[string]interface{}{"uri": "file://$ROOT/.env"},
+				Want:   isErrorMessage(-32603, `resources/read on $ROOT/.env is denied by policy rule "secrets"`),
+			},
+		},
+	},
+	{
+		Name: "resources",
+		Args: []string{"-max-read-bytes", "64"},
+		Files: map[string]string{
+			"notes.txt": "Hello, MCP!\n",
+			"data.bin":  "\x00\x01\x02",
+			"big.log":   numberedLines(20),
+		},
+		Steps: []step{
+			{
+				Method: "resources/list",
+				Want: isResult(`{"resources":[` +
+					`{"uri":"file://$ROOT/big.log","name":"work/big.log","mimeType":"text/x-log","size":151},` +
+					`{"uri":"file://$ROOT/data.bin","name":"work/data.bin","mimeType":"application/octet-stream","size":3},` +
+					`{"uri":"file://$ROOT/notes.txt","name":"work/notes.txt","mimeType":"text/plain","size":12}]}`),
+				SDK:   isResourceList("work/big.log", "work/data.bin", "work/notes.txt"),
+				Drift: resourceSizeDrift,
+			},
+			{
+				Method: "resources/templates/list",
+				Want:   isResult(`{"resourceTemplates":[{"uriTemplate":"file:///{+path}","name":"file","description":"A file in one of the allowed directories, addressed by its absolute path"}]}`),
+			},
+			{
+				Method: "resources/read",
+				Params: map[string]interface{}{"uri": "file://$ROOT/notes.txt"},
+				Want:   isResult(`{"contents":[{"uri":"file://$ROOT/notes.txt","mimeType":"text/plain","text":"Hello, MCP!\n"}]}`),
+			},
+			{
+				Method: "resources/read",
+				Params: map[string]interface{}{"uri": "file://$ROOT/data.bin"},
+				Want:   isResult(`{"contents":[{"uri":"file://$ROOT/data.bin","mimeType":"application/octet-stream","blob":"AAEC"}]}`),
+			},
+			{
+				Method: "resources/read",
+				Params: map[string]interface{}{"uri": "file://$ROOT/missing.txt"},
+				Want:   isErrorMessage(-32002, "resource not found: file://$ROOT/missing.txt"),
+				SDK:    isErrorMessage(-32603, "resource not found: file://$ROOT/missing.txt"),
+				Drift:  resourceErrorDrift,
+			},
+			{
+				Method: "resources/read",
+				Params: map[string]interface{}{"uri": "file://$ROOT/big.log"},
+				Want:   isErrorMessage(-32603, "big.log is 151 bytes, more than the 64 bytes a resource read returns"),
+			},
+			{
+				Method: "resources/list",
+				Params: map[string]interface{}{"cursor": "not a cursor"},
+				Want:   isErrorMessage(-32602, "Invalid cursor"),
+				SDK:    isError(-32602),
+				Drift:  "mcp-go decodes cursors itself and reports the base64 error as the message",
+			},
+		},
+	},
+	{
+		Name: "JSON-RPC errors",
+		Steps: []step{
+			{Raw: `{"jsonrpc":"2.0","id":"a","method":"ping"`, Want: isError(-32700)},
+			{
+				Raw:   `[]`,
+				Want:  isErrorMessage(-32600, "empty batch"),
+				SDK:   isError(-32700),
+				Drift: batchDrift,
+			},
+			{
+				Raw:   `[{"jsonrpc":"2.0","id":"b","method":"ping"},{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"x"}},{"jsonrpc":"2.0","id":"c","method":"files/list"}]`,
+				Want:  isBatch(isResult(`{}`), isError(-32601)),
+				SDK:   isError(-32700),
+				Drift: batchDrift,
+			},
+			{
+				Raw:   `{"jsonrpc":"2.0","id":"d","method":42}`,
+				Want:  isError(-32600),
+				SDK:   isError(-32700),
+				Drift: "mcp-go answers a message it cannot decode into a request with a parse error",
+			},
+			{Raw: `{"jsonrpc":"2.0","id":"e","method":"ping"}`, Want: isResult(`{}`)},
+		},
+	},
+	{
+		Name:            "protocol 2025-03-26 has annotations but no structured output",
+		ProtocolVersion: "2025-03-26",
+		Steps: []step{
+			{Method: "tools/list", Want: hasTools(func(tools map[string]toolInfo) error {
+				for name, tool := range tools {
+					if len(tool.Annotations) == 0 {
+						return fmt.Errorf("tool %s has no annotations", name)
+					}
+					if tool.OutputSchema != nil {
+						return fmt.Errorf("tool %s has an output schema", name)
+					}
+				}
+				return nil
+			})},
+			{
+				Method: "tools/call",
+				Params: callParams("list_allowed_directories", nil),
+				Want: hasToolResult(func(result toolResult) error {
+					if result.StructuredContent != nil {
+						return fmt.Errorf("unexpected structuredContent")
+					}
+					if want := "Allowed directories:\nwork: $ROOT (read-write)"; result.text() != want {
+						return fmt.Errorf("got text %q, want %q", result.text(), want)
+					}
+					return nil
+				}),
+			},
+		},
+	},
+	{
+		Name:              "unknown protocol version negotiates the latest",
+		ProtocolVersion:   "2099-01-01",
+		NegotiatedVersion: "2025-06-18",
+		Steps: []step{
+			{Method: "ping", Want: isResult(`{}`)},
+		},
+	},
+}
+
+// numberedLines returns n lines reading "line 1" to "line n"
+func numberedLines(n int) string {
+	var lines strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&lines, "line %d\n", i)
+	}
+	return lines.String()
+}
+
+// callParams returns the params of a tools/call request
+func callParams(name string, arguments map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{"name": name}
+	if arguments != nil {
+		params["arguments"] = arguments
+	}
+	return params
+}
+
+// isResult checks for a result equal to the given JSON
+func isResult(want string) check {
+	return func(r *response) error {
+		if r.Error != nil {
+			return fmt.Errorf("got error %d %q, want result", r.Error.Code, r.Error.Message)
+		}
+		var got, expected interface{}
+		json.Unmarshal(r.Result, &got)
+		json.Unmarshal([]byte(want), &expected)
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			return fmt.Errorf("got result %s, want %s", r.Result, want)
+		}
+		return nil
+	}
+}
+
+// isError checks for a JSON-RPC error with the given code
+func isError(code int) check {
+	return isErrorMessage(code, "")
+}
+
+// isErrorMessage checks for a JSON-RPC error with the given code whose message contains text
+func isErrorMessage(code int, text string) check {
+	return func(r *response) error {
+		if r.Error == nil {
+			return fmt.Errorf("got result %s, want error %d", r.Result, code)
+		}
+		if r.Error.Code != code {
+			return fmt.Errorf("got error %d %q, want %d", r.Error.Code, r.Error.Message, code)
+		}
+		if !strings.Contains(r.Error.Message, text) {
+			return fmt.Errorf("got error message %q, want it to contain %q", r.Error.Message, text)
+		}
+		return nil
+	}
+}
+
+// isBatch checks for a batch response whose items pass the given checks in order
+func isBatch(items ...check) check {
+	return func(r *response) error {
+		if r.Batch == nil {
+			return fmt.Errorf("got %s, want a batch", describe(r))
+		}
+		if len(r.Batch) != len(items) {
+			return fmt.Errorf("got %s, want %d items", describe(r), len(items))
+		}
+		for i, item := range items {
+			if err := item(r.Batch[i]); err != nil {
+				return fmt.Errorf("batch item %d: %v", i+1, err)
+			}
+		}
+		return nil
+	}
+}
+
+// isResourceList checks for a resources/list result listing exactly the
+// resources with the given names, in order
+func isResourceList(names ...string) check {
+	return func(r *response) error {
+		if r.Error != nil {
+			return fmt.Errorf("got error %d %q, want result", r.Error.Code, r.Error.Message)
+		}
+		var result struct {
+			Resources []struct {
+				Name string `json:"name"`
+			} `json:"resources"`
+		}
+		if err := json.Unmarshal(r.Result, &result); err != nil {
+			return fmt.Errorf("invalid resources/list result: %v", err)
+		}
+		var got []string
+		for _, resource := range result.Resources {
+			got = append(got, resource.Name)
+		}
+		if strings.Join(got, ", ") != strings.Join(names, ", ") {
+			return fmt.Errorf("got resources %v, want %v", got, names)
+		}
+		return nil
+	}
+}
+
+type toolInfo struct {
+	Annotations  map[string]interface{} `json:"annotations"`
+	OutputSchema json.RawMessage        `json:"outputSchema"`
+}
+
+// hasTools checks a tools/list result with fn, which gets the tools by name
+func hasTools(fn func(tools map[string]toolInfo) error) check {
+	return func(r *response) error {
+		if r.Error != nil {
+			return fmt.Errorf("got error %d %q, want result", r.Error.Code, r.Error.Message)
+		}
+		var result struct {
+			Tools []struct {
+				Name string `json:"name"`
+				toolInfo
+			} `json:"tools"`
+		}
+		if err := json.Unmarshal(r.Result, &result); err != nil {
+			return fmt.Errorf("invalid tools/list result: %v", err)
+		}
+		tools := make(map[string]toolInfo)
+		for _, tool := range result.Tools {
+			tools[tool.Name] = tool.toolInfo
+		}
+		return fn(tools)
+	}
+}
+
+type toolResult struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent"`
+	IsError           bool            `json:"isError"`
+}
+
+// text joins the text items of the result
+func (t toolResult) text() string {
+	var texts []string
+	for _, content := range t.Content {
+		texts = append(texts, content.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// hasToolResult checks a tools/call result with fn
+func hasToolResult(fn func(result toolResult) error) check {
+	return func(r *response) error {
+		if r.Error != nil {
+			return fmt.Errorf("got error %d %q, want result", r.Error.Code, r.Error.Message)
+		}
+		var result toolResult
+		if err := json.Unmarshal(r.Result, &result); err != nil {
+			return fmt.Errorf("invalid tools/call result: %v", err)
+		}
+		return fn(result)
+	}
+}
+
+// isToolText checks for a successful tool result with exactly the given text
+func isToolText(want string) check {
+	return hasToolResult(func(result toolResult) error {
+		if result.IsError {
+			return fmt.Errorf("tool failed: %s", result.text())
+		}
+		if got := result.text(); got != want {
+			return fmt.Errorf("got text %q, want %q", got, want)
+		}
+		return nil
+	})
+}
+
+// isToolError checks for a failed tool result whose text contains text
+func isToolError(text string) check {
+	return hasToolResult(func(result toolResult) error {
+		if !result.IsError {
+			return fmt.Errorf("got success %q, want a failed result", result.text())
+		}
+		if got := result.text(); !strings.Contains(got, text) {
+			return fmt.Errorf("got failure %q, want it to contain %q", got, text)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,176 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Copy copies the file or directory tree at src to dst through the rooted
+// handles, which may be in different allowed directories. Permissions and
+// modification times are preserved and symlinks are copied as links rather
+// than followed. Directories are merged into existing ones; an existing file
+// at a destination path is an error, or is left alone and returned in
+// skipped when skipExisting is set. Progress is reported in bytes copied.
+func (v *Validator) Copy(ctx context.Context, src, dst string, skipExisting bool) (skipped []string, err error) {
+	if _, _, err := v.open(src, false); err != nil {
+		return nil, err
+	}
+	if _, _, err := v.open(dst, true); err != nil {
+		return nil, err
+	}
+
+	var progress *ProgressTracker
+	if _, tracking := ctx.Value(progressKey{}).(ProgressFunc); tracking {
+		var total int64
+		v.WalkDir(ctx, src, func(name string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					total += info.Size()
+				}
+			}
+			return nil
+		})
+		progress = TrackProgress(ctx, total)
+		defer progress.Finish()
+	}
+
+	// Directories get their own permissions and times once their contents
+	// are in place, as writing into them changes the times and a read-only
+	// directory could not be filled
+	type createdDir struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []createdDir
+
+	err = v.WalkDir(ctx, src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		dstRoot, targetRel, err := v.open(target, true)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		existing, err := dstRoot.Lstat(targetRel)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if exists && existing.IsDir() {
+				return nil
+			}
+			if exists {
+				if skipExisting {
+					skipped = append(skipped, target)
+					return fs.SkipDir
+				}
+				return fmt.Errorf("%s already exists and is not a directory", target)
+			}
+			if err := dstRoot.Mkdir(targetRel, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, createdDir{target, info})
+			return nil
+
+		case exists:
+			if skipExisting {
+				skipped = append(skipped, target)
+				return nil
+			}
+			return fmt.Errorf("%s already exists", target)
+
+		case d.Type()&fs.ModeSymlink != 0:
+			srcRoot, srcRel, err := v.open(name, false)
+			if err != nil {
+				return err
+			}
+			link, err := srcRoot.Readlink(srcRel)
+			if err != nil {
+				return err
+			}
+			return dstRoot.Symlink(link, targetRel)
+
+		case d.Type().IsRegular():
+			return v.copyFile(ctx, name, target, info, progress)
+
+		default:
+			return fmt.Errorf("cannot copy %s: only regular files, directories and symlinks can be copied", name)
+		}
+	})
+	if err != nil {
+		return skipped, err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		root, rel, err := v.open(dirs[i].path, true)
+		if err != nil {
+			return skipped, err
+		}
+		if err := root.Chmod(rel, dirs[i].info.Mode().Perm()); err != nil {
+			return skipped, err
+		}
+		if err := root.Chtimes(rel, time.Time{}, dirs[i].info.ModTime()); err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// copyFile copies the content, permissions and modification time of the
+// regular file src to dst, which must not exist yet. A partial copy is
+// removed again when copying fails.
+func (v *Validator) copyFile(ctx context.Context, src, dst string, info fs.FileInfo, progress *ProgressTracker) error {
+	srcRoot, srcRel, err := v.open(src, false)
+	if err != nil {
+		return err
+	}
+	dstRoot, dstRel, err := v.open(dst, true)
+	if err != nil {
+		return err
+	}
+
+	in, err := srcRoot.Open(srcRel)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := dstRoot.OpenFile(dstRel, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, contextReader{ctx, in, progress})
+	if err == nil {
+		// OpenFile applies the umask
+		err = out.Chmod(info.Mode().Perm())
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = dstRoot.Chtimes(dstRel, time.Time{}, info.ModTime())
+	}
+	if err != nil {
+		dstRoot.Remove(dstRel)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package filesystem
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether a slash-separated path matches a glob pattern.
+// Each path component is matched with path.Match syntax, and a "**"
+// component matches zero or more whole components.
+func MatchGlob(pattern, name string) bool {
+	return matchComponents(splitGlob(pattern), splitGlob(name))
+}
+
+// ValidateGlob checks a glob pattern for syntax errors
+func ValidateGlob(pattern string) error {
+	for _, component := range splitGlob(pattern) {
+		if _, err := path.Match(component, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchComponents matches pattern components against path components
+func matchComponents(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for len(patterns) > 0 && patterns[0] == "**" {
+				patterns = patterns[1:]
+			}
+			if len(patterns) == 0 {
+				return true
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchComponents(patterns, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+
+		matched, err := path.Match(patterns[0], names[0])
+		if err != nil || !matched {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// splitGlob splits a slash-separated pattern or path into components,
+// treating "" and "." as the empty path
+func splitGlob(name string) []string {
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
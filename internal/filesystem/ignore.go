@@ -0,0 +1,145 @@
+package filesystem
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileNames are the ignore files honored in every directory, in the
+// order they are applied. Rules in .mcpignore come last so they can override
+// .gitignore for agents without touching the repository's git configuration.
+var IgnoreFileNames = []string{".gitignore", ".mcpignore"}
+
+// ignoreRule is a single parsed line of an ignore file
+type ignoreRule struct {
+	// pattern is a slash-separated glob relative to the ignore file's directory
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// IgnoreFilter decides whether paths are excluded by .gitignore and .mcpignore
+// files using gitignore semantics: nested files apply to their own subtree,
+// later and deeper rules override earlier ones, "!" re-includes a path and a
+// trailing "/" restricts a pattern to directories. Only ignore files inside
+// the allowed directory containing a path are consulted.
+//
+// A filter caches the ignore files it reads, so create a new one per operation
+// to observe edits made in between.
+type IgnoreFilter struct {
+	v     *Validator
+	rules map[string][]ignoreRule
+}
+
+// NewIgnoreFilter creates an ignore filter reading ignore files through the validator's rooted handles
+func (v *Validator) NewIgnoreFilter() *IgnoreFilter {
+	return &IgnoreFilter{
+		v:     v,
+		rules: make(map[string][]ignoreRule),
+	}
+}
+
+// Ignored reports whether a validated path is excluded by the ignore files
+// in its allowed directory. Ancestor directories are not checked; callers
+// walking a tree are expected to skip ignored directories themselves.
+func (f *IgnoreFilter) Ignored(name string, isDir bool) bool {
+	root, rel, err := f.v.relative(name)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	ignored := false
+	dir := ""
+	components := strings.Split(rel, "/")
+	for i := range components {
+		sub := strings.Join(components[i:], "/")
+		for _, rule := range f.load(root, dir) {
+			if rule.matches(sub, isDir) {
+				ignored = !rule.negate
+			}
+		}
+		dir = path.Join(dir, components[i])
+	}
+
+	return ignored
+}
+
+// load returns the rules of the ignore files in a directory, relative to its root
+func (f *IgnoreFilter) load(root *Root, dir string) []ignoreRule {
+	key := root.Dir + "\x00" + dir
+	if rules, ok := f.rules[key]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	for _, name := range IgnoreFileNames {
+		data, err := root.root.ReadFile(filepath.FromSlash(path.Join(dir, name)))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, parseIgnoreFile(string(data))...)
+	}
+
+	f.rules[key] = rules
+	return rules
+}
+
+// parseIgnoreFile parses the contents of a gitignore-style file
+func parseIgnoreFile(content string) []ignoreRule {
+	var rules []ignoreRule
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+
+		// Trailing spaces are ignored unless escaped
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		// A slash anywhere but the end anchors the pattern to the ignore
+		// file's directory; otherwise it matches at any depth
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+
+		if line == "" || ValidateGlob(line) != nil {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// matches reports whether the rule applies to a slash-separated path
+// relative to the ignore file's directory
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return MatchGlob(r.pattern, rel)
+	}
+	return MatchGlob("**/"+r.pattern, rel)
+}
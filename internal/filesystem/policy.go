@@ -0,0 +1,174 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy is a declarative access policy evaluated after path validation.
+// Rules are checked in order and the first rule matching both the tool and
+// the path decides; when no rule matches, Default applies.
+type Policy struct {
+	// Default is "allow" or "deny"; an empty value allows
+	Default string       `json:"default,omitempty"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// PolicyRule allows or denies a set of tools access to paths matching any of its globs.
+// Globs are matched against the slash-separated path relative to the allowed
+// directory containing it, unless they start with "/", in which case they are
+// matched against the absolute path.
+type PolicyRule struct {
+	// Name identifies the rule in denial messages
+	Name string `json:"name,omitempty"`
+	// Action is "allow" or "deny"
+	Action string `json:"action"`
+	// Tools limits the rule to the named tools; empty means every tool
+	Tools []string `json:"tools,omitempty"`
+	Paths []string `json:"paths"`
+}
+
+// LoadPolicy reads and validates a JSON policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading policy file %s: %v", path, err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("error parsing policy file %s: %v", path, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+
+	return &policy, nil
+}
+
+// SetPolicy installs an access policy that Authorize evaluates; nil removes it
+func (v *Validator) SetPolicy(policy *Policy) {
+	v.policy = policy
+}
+
+// Authorize checks a validated path against the access policy for the named tool
+func (v *Validator) Authorize(tool, path string) error {
+	if v.policy == nil {
+		return nil
+	}
+
+	_, rel, err := v.relative(path)
+	if err != nil {
+		return err
+	}
+
+	return v.policy.check(tool, filepath.ToSlash(rel), filepath.ToSlash(path))
+}
+
+// AuthorizeTree is like Authorize for tools that act on a whole directory
+// tree, such as delete_file. Every existing entry inside the tree is checked
+// too, so a directory cannot be removed when it contains a denied path.
+func (v *Validator) AuthorizeTree(ctx context.Context, tool, path string) error {
+	if v.policy == nil {
+		return nil
+	}
+
+	// The path itself is checked even when it does not exist or is a
+	// dangling symlink, which the walk would not report
+	if err := v.Authorize(tool, path); err != nil {
+		return err
+	}
+
+	return v.WalkDir(ctx, path, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Missing paths are reported by the operation itself
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		return v.Authorize(tool, name)
+	})
+}
+
+// check evaluates the policy for a path given both relative to its allowed
+// directory and in absolute form
+func (p *Policy) check(tool, rel, abs string) error {
+	for i, rule := range p.Rules {
+		if !rule.appliesTo(tool) {
+			continue
+		}
+
+		for _, pattern := range rule.Paths {
+			name := rel
+			if strings.HasPrefix(pattern, "/") {
+				name = abs
+			}
+
+			if MatchGlob(pattern, name) {
+				if rule.Action == "deny" {
+					return fmt.Errorf("access denied: %s on %s is denied by policy rule %s (%s)", tool, abs, rule.label(i), pattern)
+				}
+				return nil
+			}
+		}
+	}
+
+	if p.Default == "deny" {
+		return fmt.Errorf("access denied: %s on %s is not allowed by any policy rule", tool, abs)
+	}
+	return nil
+}
+
+// validate checks actions and glob syntax so that mistakes surface at startup
+func (p *Policy) validate() error {
+	switch p.Default {
+	case "", "allow", "deny":
+	default:
+		return fmt.Errorf("default must be \"allow\" or \"deny\", got %q", p.Default)
+	}
+
+	for i, rule := range p.Rules {
+		if rule.Action != "allow" && rule.Action != "deny" {
+			return fmt.Errorf("rule %s: action must be \"allow\" or \"deny\", got %q", rule.label(i), rule.Action)
+		}
+		if len(rule.Paths) == 0 {
+			return fmt.Errorf("rule %s: at least one path glob is required", rule.label(i))
+		}
+		for _, pattern := range rule.Paths {
+			if err := ValidateGlob(pattern); err != nil {
+				return fmt.Errorf("rule %s: %v", rule.label(i), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// appliesTo reports whether the rule is scoped to the named tool
+func (r PolicyRule) appliesTo(tool string) bool {
+	if len(r.Tools) == 0 {
+		return true
+	}
+	for _, t := range r.Tools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// label names the rule in messages, falling back to its position
+func (r PolicyRule) label(index int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("%q", r.Name)
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
@@ -0,0 +1,73 @@
+package filesystem
+
+import (
+	"context"
+	"time"
+)
+
+// ProgressInterval is the minimum time between two progress reports of one
+// operation. Operations that finish sooner report no progress at all.
+const ProgressInterval = 250 * time.Millisecond
+
+// ProgressFunc receives the progress of a long-running operation. Total is 0
+// when it is not known in advance.
+type ProgressFunc func(progress, total int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context under which long-running operations, such
+// as reading large files or removing directory trees, report their progress
+// to fn. A nil fn turns off reporting for operations run under the context.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ProgressTracker accumulates the progress of one operation and reports it
+// at most once per ProgressInterval
+type ProgressTracker struct {
+	fn       ProgressFunc
+	progress int64
+	total    int64
+	last     time.Time
+	reported bool
+	// sent is the progress of the last report
+	sent int64
+}
+
+// TrackProgress starts tracking an operation, in this package or in a caller
+// such as a search walking many files. It returns nil, on which all
+// methods are no-ops, when ctx carries no ProgressFunc
+func TrackProgress(ctx context.Context, total int64) *ProgressTracker {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	if fn == nil {
+		return nil
+	}
+	return &ProgressTracker{fn: fn, total: total, last: time.Now()}
+}
+
+// Add records n more units of work done
+func (t *ProgressTracker) Add(n int64) {
+	if t == nil {
+		return
+	}
+
+	t.progress += n
+	if time.Since(t.last) >= ProgressInterval {
+		t.report()
+	}
+}
+
+// Finish sends a final report if progress was reported before and has moved
+// since, so that clients showing progress see the operation complete
+func (t *ProgressTracker) Finish() {
+	if t != nil && t.reported && t.progress != t.sent {
+		t.report()
+	}
+}
+
+func (t *ProgressTracker) report() {
+	t.fn(t.progress, t.total)
+	t.last = time.Now()
+	t.reported = true
+	t.sent = t.progress
+}
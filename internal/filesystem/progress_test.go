@@ -0,0 +1,74 @@
+package filesystem
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// recordProgress returns a tracker whose reports are appended to the returned slice
+func recordProgress(total int64) (*ProgressTracker, *[]int64) {
+	var reports []int64
+	ctx := WithProgress(context.Background(), func(progress, total int64) {
+		reports = append(reports, progress)
+	})
+	return TrackProgress(ctx, total), &reports
+}
+
+func TestProgressFinish(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(*ProgressTracker)
+		want []int64
+	}{
+		{
+			name: "quick operation",
+			run:  func(p *ProgressTracker) { p.Add(10) },
+			want: nil,
+		},
+		{
+			name: "moved since last report",
+			run: func(p *ProgressTracker) {
+				p.last = time.Now().Add(-ProgressInterval)
+				p.Add(5)
+				p.Add(5)
+			},
+			want: []int64{5, 10},
+		},
+		{
+			name: "unchanged since last report",
+			run: func(p *ProgressTracker) {
+				p.Add(5)
+				p.last = time.Now().Add(-ProgressInterval)
+				p.Add(5)
+			},
+			want: []int64{10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, reports := recordProgress(10)
+			tt.run(p)
+			p.Finish()
+			if !slices.Equal(*reports, tt.want) {
+				t.Errorf("got reports %v, want %v", *reports, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressDisabled(t *testing.T) {
+	if p := TrackProgress(WithProgress(context.Background(), nil), 10); p != nil {
+		t.Fatal("got a tracker for a nil ProgressFunc")
+	}
+	if p := TrackProgress(context.Background(), 10); p != nil {
+		t.Fatal("got a tracker without a ProgressFunc")
+	}
+
+	// A nil tracker ignores all calls
+	var p *ProgressTracker
+	p.Add(1)
+	p.Finish()
+}
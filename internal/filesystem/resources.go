@@ -0,0 +1,268 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ResourcePolicyName is the name resource access is checked under in access
+// policies, alongside tool names such as read_file
+const ResourcePolicyName = "resources/read"
+
+// MaxListedResources caps how many files are listed at once so that
+// listing a huge tree cannot exhaust memory
+const MaxListedResources = 10000
+
+// FileURITemplate is the RFC 6570 template for file resources. The "+"
+// operator lets the path variable span several segments.
+const FileURITemplate = "file:///{+path}"
+
+// FileResource describes a file exposed as an MCP resource
+type FileResource struct {
+	URI      string
+	Name     string
+	MIMEType string
+	Size     int64
+
+	// rel is the slash separated path in its allowed directory
+	rel string
+}
+
+// ResourceContents holds the contents of a file resource. Exactly one of
+// Text and Blob is set; Blob is base64 encoded.
+type ResourceContents struct {
+	URI      string
+	MIMEType string
+	Text     *string
+	Blob     *string
+}
+
+// ErrResourceNotFound is returned when a resource URI does not name a readable file
+var ErrResourceNotFound = errors.New("resource not found")
+
+// FileURI returns the file:// URI for an absolute path
+func FileURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+// PathFromURI returns the absolute path named by a file:// URI
+func PathFromURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid resource URI %s: %v", uri, err)
+	}
+	if u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+		return "", fmt.Errorf("unsupported resource URI %s: only local file:// URIs are supported", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// MIMEType guesses the MIME type of a file from its extension, falling back
+// to sniffing its content when data is available. It returns "" when the
+// extension is unknown and there is no data to sniff.
+func MIMEType(name string, data []byte) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		if data == nil {
+			return ""
+		}
+		mimeType = http.DetectContentType(data)
+	}
+
+	// Drop parameters such as charset
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return mimeType
+}
+
+// IsText reports whether data can be returned as text: valid UTF-8 without NUL bytes
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.Contains(data, []byte{0})
+}
+
+// ErrInvalidCursor is returned when a resource listing cursor was not
+// produced by ListFileResources
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrResourceTooLarge is returned when a file is larger than a resource read may return
+var ErrResourceTooLarge = errors.New("resource too large")
+
+// ListFileResources lists up to limit regular files in the allowed
+// directories as resources, skipping files denied by the access policy and,
+// when ignore is not nil, files excluded by ignore files. The listing starts
+// after the file named by cursor, or at the beginning when cursor is empty,
+// and next is the cursor to continue from, or "" when there are no more files.
+// Directories that lie entirely before the cursor are not walked again.
+func (v *Validator) ListFileResources(ctx context.Context, ignore *IgnoreFilter, cursor string, limit int) (resources []FileResource, next string, err error) {
+	start, after, err := decodeResourceCursor(cursor, len(v.roots))
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := start; i < len(v.roots) && next == ""; i++ {
+		root := v.roots[i]
+		err := v.WalkDir(ctx, root.Dir, func(path string, d fs.DirEntry, err error) error {
+			rel, _ := filepath.Rel(root.Dir, path)
+			rel = filepath.ToSlash(rel)
+
+			if i == start && after != nil && path != root.Dir {
+				// Skip what earlier pages have covered
+				order := compareWalkOrder(strings.Split(rel, "/"), after)
+				if d != nil && d.IsDir() && order < 0 && !isPathPrefix(strings.Split(rel, "/"), after) {
+					return fs.SkipDir
+				}
+				if order <= 0 && (d == nil || !d.IsDir()) {
+					return nil
+				}
+			}
+
+			if err != nil {
+				// Unreadable entries are left out rather than failing the listing
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if path != root.Dir && ignore != nil && ignore.Ignored(path, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if !d.Type().IsRegular() || v.Authorize(ResourcePolicyName, path) != nil {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+
+			if len(resources) == limit {
+				next = encodeResourceCursor(i, resources[len(resources)-1].rel)
+				return fs.SkipAll
+			}
+
+			resources = append(resources, FileResource{
+				URI:      FileURI(path),
+				Name:     filepath.ToSlash(filepath.Join(root.Name, rel)),
+				MIMEType: MIMEType(path, nil),
+				Size:     info.Size(),
+				rel:      rel,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return resources, next, nil
+}
+
+// encodeResourceCursor returns an opaque cursor naming the file rel, a
+// slash separated path in the allowed directory with the given index
+func encodeResourceCursor(root int, rel string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(root) + ":" + rel))
+}
+
+// decodeResourceCursor returns the index of the allowed directory and the
+// path components of the file named by a cursor, or 0 and nil for the
+// empty cursor
+func decodeResourceCursor(cursor string, roots int) (int, []string, error) {
+	if cursor == "" {
+		return 0, nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, nil, ErrInvalidCursor
+	}
+	index, rel, ok := strings.Cut(string(data), ":")
+	if !ok || rel == "" {
+		return 0, nil, ErrInvalidCursor
+	}
+	root, err := strconv.Atoi(index)
+	if err != nil || root < 0 || root >= roots {
+		return 0, nil, ErrInvalidCursor
+	}
+	return root, strings.Split(rel, "/"), nil
+}
+
+// compareWalkOrder compares two paths, given as components, in the order
+// WalkDir visits them: component by component by name, parents first
+func compareWalkOrder(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// isPathPrefix reports whether the path dir, given as components, is path or one of its parents
+func isPathPrefix(dir, path []string) bool {
+	return len(dir) <= len(path) && slices.Equal(dir, path[:len(dir)])
+}
+
+// ReadFileResource reads the file named by a file:// URI, validating and
+// authorizing it like any other access. Text files are returned as text and
+// everything else as a base64 blob. Files larger than maxBytes are refused
+// with ErrResourceTooLarge.
+func (v *Validator) ReadFileResource(ctx context.Context, uri string, maxBytes int64) (*ResourceContents, error) {
+	path, err := PathFromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	validPath, err := v.ValidatePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.Authorize(ResourcePolicyName, validPath); err != nil {
+		return nil, err
+	}
+
+	// Read one byte more than allowed to notice files that grew since they were sized
+	data, size, err := v.ReadFileRange(ctx, validPath, 0, maxBytes+1)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+		}
+		return nil, fmt.Errorf("error reading resource %s: %v", uri, err)
+	}
+	if size > maxBytes || int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: %s is %d bytes, more than the %d bytes a resource read returns; use read_file to read it in parts",
+			ErrResourceTooLarge, uri, max(size, int64(len(data))), maxBytes)
+	}
+
+	contents := &ResourceContents{
+		URI:      uri,
+		MIMEType: MIMEType(validPath, data),
+	}
+	if IsText(data) {
+		text := string(data)
+		contents.Text = &text
+	} else {
+		blob := base64.StdEncoding.EncodeToString(data)
+		contents.Blob = &blob
+	}
+
+	return contents, nil
+}
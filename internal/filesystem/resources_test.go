@@ -0,0 +1,106 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListFileResourcesPages(t *testing.T) {
+	v, root, _ := testTree(t)
+
+	var want []string
+	for _, name := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "b/f.txt", "sub/f.txt", "z.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, "root/"+name)
+	}
+
+	for limit := 1; limit <= len(want)+1; limit++ {
+		var got []string
+		cursor, pages := "", 0
+		for {
+			files, next, err := v.ListFileResources(context.Background(), nil, cursor, limit)
+			if err != nil {
+				t.Fatalf("limit %d: %v", limit, err)
+			}
+			if len(files) > limit {
+				t.Fatalf("limit %d: got %d files", limit, len(files))
+			}
+			for _, file := range files {
+				got = append(got, file.Name)
+			}
+			if pages++; next == "" || pages > len(want) {
+				break
+			}
+			cursor = next
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("limit %d: got %v, want %v", limit, got, want)
+		}
+	}
+}
+
+func TestListFileResourcesResumesAfterChanges(t *testing.T) {
+	v, root, _ := testTree(t)
+	for _, name := range []string{"a.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, next, err := v.ListFileResources(context.Background(), nil, "", 1)
+	if err != nil || len(files) != 1 || files[0].Name != "root/a.txt" || next == "" {
+		t.Fatalf("first page: %v %q %v", files, next, err)
+	}
+
+	// Files removed or created before the cursor do not shift the next page
+	if err := os.Remove(filepath.Join(root, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "0.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _, err = v.ListFileResources(context.Background(), nil, next, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, file := range files {
+		got = append(got, file.Name)
+	}
+	if want := []string{"root/c.txt", "root/sub/f.txt"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestListFileResourcesInvalidCursor(t *testing.T) {
+	v, _, _ := testTree(t)
+	for _, cursor := range []string{"???", encodeResourceCursor(1, "a"), encodeResourceCursor(0, ""), "MA"} {
+		if _, _, err := v.ListFileResources(context.Background(), nil, cursor, 10); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: got %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestReadFileResourceTooLarge(t *testing.T) {
+	v, root, _ := testTree(t)
+	uri := FileURI(filepath.Join(root, "sub", "f.txt"))
+
+	contents, err := v.ReadFileResource(context.Background(), uri, 6)
+	if err != nil || contents.Text == nil || *contents.Text != "inside" {
+		t.Fatalf("got %v, %v", contents, err)
+	}
+	if _, err := v.ReadFileResource(context.Background(), uri, 5); !errors.Is(err, ErrResourceTooLarge) {
+		t.Errorf("got %v, want ErrResourceTooLarge", err)
+	}
+}
@@ -0,0 +1,520 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Root is an allowed directory together with a rooted handle on it
+type Root struct {
+	Name string
+	Dir  string
+	Mode AccessMode
+	root *os.Root
+}
+
+// openRoot resolves the directory of a root spec and opens a rooted handle on it
+func openRoot(spec RootSpec) (*Root, error) {
+	absDir, err := filepath.Abs(spec.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving allowed directory %s: %v", spec.Dir, err)
+	}
+
+	resolvedDir, err := filepath.EvalSymlinks(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving allowed directory %s: %v", spec.Dir, err)
+	}
+
+	root, err := os.OpenRoot(resolvedDir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening allowed directory %s: %v", spec.Dir, err)
+	}
+
+	name := spec.Name
+	if name == "" {
+		name = filepath.Base(resolvedDir)
+	}
+
+	return &Root{
+		Name: name,
+		Dir:  resolvedDir,
+		Mode: spec.Mode,
+		root: root,
+	}, nil
+}
+
+// contains reports whether path lies within the directory. The check is
+// done on whole path components, so /data/base-evil is not inside /data/base.
+func (r *Root) contains(path string) bool {
+	rel, err := filepath.Rel(r.Dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// The methods below perform file access through an os.Root opened on the
+// allowed directory instead of through plain paths. ValidatePath alone is a
+// check-then-use design: a symlink swapped in between validation and use
+// could still redirect the operation outside the allowed directory. Going
+// through os.Root makes the kernel enforce confinement (openat2 with
+// RESOLVE_BENEATH on Linux), so such a swap fails instead of escaping.
+//
+// Every method accepts a path already returned by ValidatePath. Methods that
+// modify the filesystem refuse paths in read-only directories. Methods that
+// take a context stop early with its error once it is cancelled.
+
+// ReadFile reads the named file through the rooted handle
+func (v *Validator) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	data, _, err := v.ReadFileRange(ctx, path, 0, -1)
+	return data, err
+}
+
+// ReadFileRange reads up to length bytes of the named file starting at
+// offset, or the rest of the file when length is negative. It also returns
+// the size of the file, which is -1 for files that are not regular, whose
+// size is not known.
+func (v *Validator) ReadFileRange(ctx context.Context, path string, offset, length int64) ([]byte, int64, error) {
+	root, rel, err := v.open(path, false)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := root.Open(rel)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	size := int64(-1)
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, size, err
+		}
+	}
+
+	// Only the bytes to be read count towards progress
+	want := max(size-offset, 0)
+	var r io.Reader = f
+	if length >= 0 {
+		r = io.LimitReader(f, length)
+		want = min(want, length)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(int(want))
+
+	progress := TrackProgress(ctx, want)
+	defer progress.Finish()
+
+	if _, err := io.Copy(&buf, contextReader{ctx, r, progress}); err != nil {
+		return nil, size, err
+	}
+	return buf.Bytes(), size, nil
+}
+
+// ScanLines calls fn with each line of the named file, without its line
+// ending, until fn returns false or the file ends. Lines longer than
+// maxLineLength bytes are cut short, and fn is told so, so that a huge file
+// without line breaks is never held in memory. The line is only valid until
+// fn returns.
+func (v *Validator) ScanLines(ctx context.Context, path string, maxLineLength int, fn func(line []byte, cut bool) bool) error {
+	root, rel, err := v.open(path, false)
+	if err != nil {
+		return err
+	}
+
+	f, err := root.Open(rel)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var size int64
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+
+	progress := TrackProgress(ctx, size)
+	defer progress.Finish()
+
+	reader := bufio.NewReader(contextReader{ctx, f, progress})
+	var line []byte
+	var length int
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		length += len(chunk)
+		if room := maxLineLength - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		// A final line is only reported when it is not empty, as files
+		// usually end in a line break
+		if err == nil || length > 0 {
+			if err == nil {
+				length--
+			}
+			cut := length > maxLineLength
+			line = line[:min(len(line), length)]
+			if !cut {
+				line = bytes.TrimSuffix(line, []byte("\r"))
+			}
+			if !fn(line, cut) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		line = line[:0]
+		length = 0
+	}
+}
+
+// WriteFile writes data to the named file through the rooted handle, creating it if necessary
+func (v *Validator) WriteFile(path string, data []byte, perm os.FileMode) error {
+	root, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+	return root.WriteFile(rel, data, perm)
+}
+
+// ReplaceFile replaces the content of the named file with data through the
+// rooted handle. The data is written to a temporary file next to it that is
+// then renamed over it, so readers see either the old or the new content,
+// never a mix. The file keeps its permissions.
+func (v *Validator) ReplaceFile(path string, data []byte) error {
+	root, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+
+	info, err := root.Stat(rel)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(rel), "."+filepath.Base(rel)+"."+rand.Text()+".tmp")
+	f, err := root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		// OpenFile applies the umask
+		err = f.Chmod(info.Mode().Perm())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = root.Rename(tmp, rel)
+	}
+	if err != nil {
+		root.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Stat returns information about the named file through the rooted handle
+func (v *Validator) Stat(path string) (fs.FileInfo, error) {
+	root, rel, err := v.open(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return root.Stat(rel)
+}
+
+// Lstat is like Stat but describes a symlink itself rather than its target
+func (v *Validator) Lstat(path string) (fs.FileInfo, error) {
+	root, rel, err := v.open(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return root.Lstat(rel)
+}
+
+// Readlink returns the target of the named symlink through the rooted handle
+func (v *Validator) Readlink(path string) (string, error) {
+	root, rel, err := v.open(path, false)
+	if err != nil {
+		return "", err
+	}
+	return root.Readlink(rel)
+}
+
+// Symlink creates a symlink at path pointing at target through the rooted
+// handle. The target is stored as given; following the link later is
+// validated like any other path.
+func (v *Validator) Symlink(target, path string) error {
+	root, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+	return root.Symlink(target, rel)
+}
+
+// ReadDir reads the named directory through the rooted handle and returns its entries sorted by name
+func (v *Validator) ReadDir(path string) ([]os.DirEntry, error) {
+	root, rel, err := v.open(path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := root.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// MkdirAll creates a directory and any missing parents through the rooted handle
+func (v *Validator) MkdirAll(path string, perm os.FileMode) error {
+	root, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+	return root.MkdirAll(rel, perm)
+}
+
+// Remove removes a file or empty directory through the rooted handle
+func (v *Validator) Remove(path string) error {
+	root, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return fmt.Errorf("refusing to remove allowed directory %s", path)
+	}
+	return root.Remove(rel)
+}
+
+// ErrCrossDevice is returned by Rename when the two paths cannot be renamed
+// into one another because they are in different allowed directories or on
+// different filesystems; the caller can copy and delete instead
+var ErrCrossDevice = errors.New("cannot rename across allowed directories or filesystems")
+
+// Rename renames a file or directory through the rooted handle, replacing an
+// existing file at newPath as rename(2) does
+func (v *Validator) Rename(oldPath, newPath string) error {
+	oldRoot, oldRel, err := v.open(oldPath, true)
+	if err != nil {
+		return err
+	}
+	newRoot, newRel, err := v.open(newPath, true)
+	if err != nil {
+		return err
+	}
+	if oldRel == "." {
+		return fmt.Errorf("refusing to rename allowed directory %s", oldPath)
+	}
+	if newRel == "." {
+		return fmt.Errorf("refusing to replace allowed directory %s", newPath)
+	}
+	if nested := v.nestedRoot(oldPath); nested != nil {
+		return fmt.Errorf("refusing to rename %s, which contains allowed directory %s", oldPath, nested.Dir)
+	}
+	if oldRoot != newRoot {
+		return ErrCrossDevice
+	}
+
+	err = oldRoot.Rename(oldRel, newRel)
+	if errors.Is(err, syscall.EXDEV) {
+		return ErrCrossDevice
+	}
+	return err
+}
+
+// RemoveAll removes a file or directory tree through the rooted handle.
+// Directory contents are removed depth-first, so a cancelled removal leaves
+// the part of the tree it has not reached yet in place. Progress is reported
+// in entries removed.
+func (v *Validator) RemoveAll(ctx context.Context, path string) error {
+	_, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return fmt.Errorf("refusing to remove allowed directory %s", path)
+	}
+	if nested := v.nestedRoot(path); nested != nil {
+		return fmt.Errorf("refusing to remove %s, which contains allowed directory %s", path, nested.Dir)
+	}
+
+	var progress *ProgressTracker
+	if _, tracking := ctx.Value(progressKey{}).(ProgressFunc); tracking {
+		var total int64
+		v.WalkDir(ctx, path, func(name string, d fs.DirEntry, err error) error {
+			total++
+			return nil
+		})
+		progress = TrackProgress(ctx, total)
+		defer progress.Finish()
+	}
+
+	return v.removeTree(ctx, path, progress)
+}
+
+func (v *Validator) removeTree(ctx context.Context, path string, progress *ProgressTracker) error {
+	root, rel, err := v.open(path, true)
+	if err != nil {
+		return err
+	}
+
+	info, err := root.Lstat(rel)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.IsDir() {
+		entries, err := v.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := v.removeTree(ctx, filepath.Join(path, entry.Name()), progress); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := root.Remove(rel); err != nil {
+		return err
+	}
+	progress.Add(1)
+	return nil
+}
+
+// Close releases the rooted handles on all allowed directories
+func (v *Validator) Close() error {
+	var firstErr error
+	for _, root := range v.roots {
+		if err := root.root.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// nestedRoot returns an allowed directory lying strictly inside path, which
+// operations on path through the outer directory's handle would bypass, or
+// nil if there is none
+func (v *Validator) nestedRoot(path string) *Root {
+	for _, root := range v.roots {
+		if root.Dir != path && (&Root{Dir: path}).contains(root.Dir) {
+			return root
+		}
+	}
+	return nil
+}
+
+// open locates the allowed directory containing a validated path and returns
+// its rooted handle along with the path relative to it
+func (v *Validator) open(path string, write bool) (*os.Root, string, error) {
+	root, rel, err := v.relative(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if write && root.Mode == ReadOnly {
+		return nil, "", fmt.Errorf("access denied: %s is in read-only directory %s", path, root.Dir)
+	}
+
+	return root.root, rel, nil
+}
+
+// relative locates the allowed directory containing a validated path and
+// returns it along with the path relative to it
+func (v *Validator) relative(path string) (*Root, string, error) {
+	root, err := v.locate(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rel, err := filepath.Rel(root.Dir, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, rel, nil
+}
+
+// WalkDir walks the tree rooted at a validated path through the rooted
+// handle, calling fn for each entry with its absolute path. Symlinks are
+// reported but not followed, and fn may return fs.SkipDir or fs.SkipAll.
+func (v *Validator) WalkDir(ctx context.Context, path string, fn fs.WalkDirFunc) error {
+	root, rel, err := v.relative(path)
+	if err != nil {
+		return err
+	}
+
+	// fs.WalkDir follows a symlink given as its root
+	if info, err := root.root.Lstat(rel); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		err := fn(path, fs.FileInfoToDirEntry(info), nil)
+		if err == fs.SkipDir || err == fs.SkipAll {
+			return nil
+		}
+		return err
+	}
+
+	return fs.WalkDir(root.root.FS(), filepath.ToSlash(rel), func(name string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fn(filepath.Join(root.Dir, filepath.FromSlash(name)), d, err)
+	})
+}
+
+// contextReader fails reads once its context is cancelled, so that copies of
+// large files can be abandoned part way, and reports the bytes read as progress
+type contextReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress *ProgressTracker
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.progress.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// AccessMode controls whether an allowed directory may be modified
+type AccessMode int
+
+const (
+	// ReadWrite allows both reading and modifying files
+	ReadWrite AccessMode = iota
+	// ReadOnly allows reading files but refuses any modification
+	ReadOnly
+)
+
+// String returns the name used for the mode in flags and tool output
+func (m AccessMode) String() string {
+	if m == ReadOnly {
+		return "read-only"
+	}
+	return "read-write"
+}
+
+// RootSpec describes an allowed directory before it is opened
+type RootSpec struct {
+	Name string
+	Dir  string
+	Mode AccessMode
+}
+
+// ParseRootSpec parses an allowed directory given as [name=]dir[:ro|:rw].
+// The name defaults to the last element of the directory and the mode to read-write.
+// Text before the first "=" is only taken as a name when it contains no path
+// separator, so /data/a=b is a directory; write ./a=b for a relative one.
+func ParseRootSpec(value string) (RootSpec, error) {
+	var spec RootSpec
+	original := value
+
+	if name, dir, ok := strings.Cut(value, "="); ok && !strings.ContainsAny(name, "/"+string(filepath.Separator)) {
+		spec.Name = name
+		value = dir
+	}
+
+	switch {
+	case strings.HasSuffix(value, ":ro"):
+		spec.Mode = ReadOnly
+		value = strings.TrimSuffix(value, ":ro")
+	case strings.HasSuffix(value, ":rw"):
+		spec.Mode = ReadWrite
+		value = strings.TrimSuffix(value, ":rw")
+	}
+
+	if value == "" {
+		return RootSpec{}, fmt.Errorf("invalid allowed directory %q: empty path", original)
+	}
+	spec.Dir = value
+
+	return spec, nil
+}
+
+// RootSpecs collects allowed directories from a repeatable command line flag
+type RootSpecs []RootSpec
+
+// String implements flag.Value
+func (s *RootSpecs) String() string {
+	var parts []string
+	for _, spec := range *s {
+		parts = append(parts, spec.Dir)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value
+func (s *RootSpecs) Set(value string) error {
+	spec, err := ParseRootSpec(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, spec)
+	return nil
+}
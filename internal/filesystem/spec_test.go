@@ -0,0 +1,34 @@
+package filesystem
+
+import "testing"
+
+func TestParseRootSpec(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RootSpec
+	}{
+		{"/data/repo", RootSpec{Dir: "/data/repo"}},
+		{"/data/repo:ro", RootSpec{Dir: "/data/repo", Mode: ReadOnly}},
+		{"docs=/shared/docs:ro", RootSpec{Name: "docs", Dir: "/shared/docs", Mode: ReadOnly}},
+		{"repo=./repo:rw", RootSpec{Name: "repo", Dir: "./repo"}},
+		{"/data/a=b", RootSpec{Dir: "/data/a=b"}},
+		{"./a=b:ro", RootSpec{Dir: "./a=b", Mode: ReadOnly}},
+		{"x=/data/a=b", RootSpec{Name: "x", Dir: "/data/a=b"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRootSpec(tt.value)
+		if err != nil {
+			t.Errorf("ParseRootSpec(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRootSpec(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", ":ro", "docs="} {
+		if got, err := ParseRootSpec(value); err == nil {
+			t.Errorf("ParseRootSpec(%q) = %+v, want an error", value, got)
+		}
+	}
+}
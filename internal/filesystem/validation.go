@@ -1,47 +1,235 @@
 package filesystem
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 )
 
-// Validator provides path validation for a specific base directory
+// maxSymlinks bounds the number of symlinks followed while resolving a path,
+// matching the limit most kernels apply before returning ELOOP
+const maxSymlinks = 255
+
+// Validator provides path validation for a set of allowed root directories
 type Validator struct {
-	baseDir string
+	roots  []*Root
+	policy *Policy
 }
 
-// NewValidator creates a new path validator for the given base directory
-func NewValidator(baseDir string) *Validator {
-	// Clean the base directory path
-	cleanBaseDir := filepath.Clean(baseDir)
-	return &Validator{
-		baseDir: cleanBaseDir,
+// NewValidator creates a new path validator for the given root directories.
+// Every root must exist; each is made absolute and has its symlinks resolved
+// so that containment can be checked against the real location. Relative
+// paths are resolved against the first root.
+func NewValidator(specs ...RootSpec) (*Validator, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("at least one allowed directory is required")
+	}
+
+	v := &Validator{}
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		root, err := openRoot(spec)
+		if err != nil {
+			v.Close()
+			return nil, err
+		}
+		v.roots = append(v.roots, root)
+
+		if names[root.Name] {
+			v.Close()
+			return nil, fmt.Errorf("duplicate allowed directory name %q", root.Name)
+		}
+		names[root.Name] = true
 	}
+
+	return v, nil
 }
 
-// ValidatePath ensures the path is within the allowed directory and returns the clean path
+// ValidatePath ensures the path is within an allowed directory and returns the clean path.
+// Symlinks are resolved, including dangling links and links in components
+// that do not exist yet, and the resolved target must stay within an allowed directory.
 func (v *Validator) ValidatePath(path string) (string, error) {
 	cleanPath := filepath.Clean(path)
 
-	if filepath.IsAbs(cleanPath) {
-		if !strings.HasPrefix(cleanPath, v.baseDir) {
-			return "", fmt.Errorf("access denied: path outside allowed directory %s", v.baseDir)
-		}
-		return cleanPath, nil
+	if !filepath.IsAbs(cleanPath) {
+		cleanPath = filepath.Join(v.GetBaseDir(), cleanPath)
 	}
 
-	fullPath := filepath.Join(v.baseDir, cleanPath)
-	cleanFullPath := filepath.Clean(fullPath)
+	// Containment is only checked after resolution, so absolute paths that
+	// reach an allowed directory through a symlink are still accepted
+	resolvedPath, err := resolveSymlinks(cleanPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving path %s: %v", path, err)
+	}
+
+	if _, err := v.locate(resolvedPath); err != nil {
+		return "", err
+	}
+
+	return resolvedPath, nil
+}
 
-	if !strings.HasPrefix(cleanFullPath, v.baseDir) {
-		return "", fmt.Errorf("access denied: path outside allowed directory %s", v.baseDir)
+// ValidateWritePath is like ValidatePath but additionally refuses paths in read-only directories
+func (v *Validator) ValidateWritePath(path string) (string, error) {
+	validPath, err := v.ValidatePath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := v.checkWritable(validPath); err != nil {
+		return "", err
 	}
+	return validPath, nil
+}
 
-	return cleanFullPath, nil
+// checkWritable refuses a validated path in a read-only directory
+func (v *Validator) checkWritable(path string) error {
+	root, err := v.locate(path)
+	if err != nil {
+		return err
+	}
+	if root.Mode == ReadOnly {
+		return fmt.Errorf("access denied: %s is in read-only directory %s", path, root.Dir)
+	}
+	return nil
+}
+
+// ValidateLinkPath is like ValidatePath but does not resolve the final
+// component, so a symlink names the link itself rather than its target. Tools
+// acting on a directory entry, such as deleting or moving it, use this so that
+// they never reach through a link to what it points at.
+func (v *Validator) ValidateLinkPath(path string) (string, error) {
+	cleanPath := filepath.Clean(path)
+
+	if !filepath.IsAbs(cleanPath) {
+		cleanPath = filepath.Join(v.GetBaseDir(), cleanPath)
+	}
+
+	// Only the parent is resolved; the entry itself is checked where it is
+	dir, name := filepath.Split(cleanPath)
+	resolvedDir, err := resolveSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("error resolving path %s: %v", path, err)
+	}
+	entryPath := filepath.Join(resolvedDir, name)
+
+	if _, err := v.locate(entryPath); err != nil {
+		return "", err
+	}
+
+	return entryPath, nil
+}
+
+// ValidateWriteLinkPath is like ValidateLinkPath but additionally refuses paths in read-only directories
+func (v *Validator) ValidateWriteLinkPath(path string) (string, error) {
+	validPath, err := v.ValidateLinkPath(path)
+	if err != nil {
+		return "", err
+	}
+	if err := v.checkWritable(validPath); err != nil {
+		return "", err
+	}
+	return validPath, nil
 }
 
-// GetBaseDir returns the base directory for this validator
+// GetBaseDir returns the first allowed directory, against which relative paths are resolved
 func (v *Validator) GetBaseDir() string {
-	return v.baseDir
-}
\ No newline at end of file
+	return v.roots[0].Dir
+}
+
+// Roots returns the allowed directories in the order they were configured
+func (v *Validator) Roots() []*Root {
+	return v.roots
+}
+
+// locate returns the allowed directory containing path. When roots are
+// nested the innermost one wins, so a read-only directory inside a
+// read-write one stays read-only.
+func (v *Validator) locate(path string) (*Root, error) {
+	var found *Root
+	for _, root := range v.roots {
+		if root.contains(path) && (found == nil || len(root.Dir) > len(found.Dir)) {
+			found = root
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("access denied: path outside allowed directories %s", v.dirList())
+	}
+	return found, nil
+}
+
+// dirList formats the allowed directories for error messages
+func (v *Validator) dirList() string {
+	dirs := make([]string, len(v.roots))
+	for i, root := range v.roots {
+		dirs[i] = root.Dir
+	}
+	return strings.Join(dirs, ", ")
+}
+
+// resolveSymlinks resolves every symlink in an absolute path, one component
+// at a time. Unlike filepath.EvalSymlinks it does not fail on components that
+// do not exist yet, so the destination of a write can be validated before it
+// is created, and dangling links are resolved to the path they point at.
+func resolveSymlinks(path string) (string, error) {
+	volume := filepath.VolumeName(path)
+	resolved := volume + string(filepath.Separator)
+	pending := splitPath(path[len(volume):])
+	followed := 0
+
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		info, err := os.Lstat(next)
+		if err != nil {
+			// A missing component cannot be a symlink; keep resolving the
+			// rest lexically so later ".." components are still handled
+			if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+				resolved = next
+				continue
+			}
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		followed++
+		if followed > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links")
+		}
+
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.IsAbs(target) {
+			volume = filepath.VolumeName(target)
+			resolved = volume + string(filepath.Separator)
+			target = target[len(volume):]
+		}
+		pending = append(splitPath(target), pending...)
+	}
+
+	return resolved, nil
+}
+
+// splitPath splits a path into its components
+func splitPath(path string) []string {
+	return strings.Split(path, string(filepath.Separator))
+}
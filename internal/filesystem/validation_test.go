@@ -0,0 +1,414 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testTree creates an allowed directory and a sibling directory outside it
+// holding a secret, and returns a validator on the allowed directory
+func testTree(t *testing.T) (v *Validator, root, outside string) {
+	t.Helper()
+
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "f.txt"), []byte("inside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = NewValidator(RootSpec{Dir: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	return v, root, outside
+}
+
+// symlink creates a symlink named name in dir pointing at target
+func symlink(t *testing.T, dir, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	v, root, outside := testTree(t)
+
+	// Chains of links, both relative and absolute
+	symlink(t, root, "chain2", "chain1")
+	symlink(t, root, "sub", "chain2")
+	symlink(t, root, "escape2", "escape1")
+	symlink(t, root, "../outside", "escape2")
+	symlink(t, root, outside, "absolute")
+	symlink(t, root, filepath.Join(root, "sub"), "absoluteInside")
+
+	// Dangling links resolve to where they point
+	symlink(t, root, "missing.txt", "dangling")
+	symlink(t, root, "../outside/new.txt", "danglingOutside")
+	symlink(t, root, "nodir/deeper", "danglingDir")
+
+	// Links that never resolve
+	symlink(t, root, "loop2", "loop1")
+	symlink(t, root, "loop1", "loop2")
+
+	tests := []struct {
+		name string
+		path string
+		want string // "" when the path must be refused
+	}{
+		{"plain file", "sub/f.txt", "sub/f.txt"},
+		{"absolute path", filepath.Join(root, "sub"), "sub"},
+		{"root itself", ".", "."},
+		{"missing file", "new.txt", "new.txt"},
+		{"missing parents", "a/b/c.txt", "a/b/c.txt"},
+		{"dot dot inside", "sub/../sub/f.txt", "sub/f.txt"},
+		{"dot dot escape", "../outside/secret.txt", ""},
+		{"dot dot escape through missing parent", "nodir/../../outside/secret.txt", ""},
+		{"absolute outside", filepath.Join(outside, "secret.txt"), ""},
+		{"link chain", "chain1/f.txt", "sub/f.txt"},
+		{"link chain escape", "escape1/secret.txt", ""},
+		{"absolute link outside", "absolute/secret.txt", ""},
+		{"absolute link inside", "absoluteInside/f.txt", "sub/f.txt"},
+		{"link in missing parent", "absolute/new/deep.txt", ""},
+		{"dangling link", "dangling", "missing.txt"},
+		{"dangling link outside", "danglingOutside", ""},
+		{"dangling link to missing directory", "danglingDir/file.txt", "nodir/deeper/file.txt"},
+		{"dot dot after link", "chain1/../sub/f.txt", "sub/f.txt"},
+		{"link loop", "loop1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.ValidatePath(tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ValidatePath(%q) = %q, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidatePath(%q) failed: %v", tt.path, err)
+			}
+			if want := filepath.Join(root, tt.want); got != want {
+				t.Errorf("ValidatePath(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestValidateLinkPath(t *testing.T) {
+	v, root, outside := testTree(t)
+	symlink(t, root, "sub/f.txt", "flink")
+	symlink(t, root, outside, "outlink")
+	symlink(t, root, "sub", "dirlink")
+	symlink(t, root, "nowhere", "dangling")
+
+	tests := []struct {
+		name string
+		path string
+		want string // "" when the path must be refused
+	}{
+		{"link to file names the link", "flink", "flink"},
+		{"link outside names the link", "outlink", "outlink"},
+		{"dangling link names the link", "dangling", "dangling"},
+		{"parent link is resolved", "dirlink/f.txt", "sub/f.txt"},
+		{"parent link outside", "outlink/secret.txt", ""},
+		{"plain file", "sub/f.txt", "sub/f.txt"},
+		{"dot dot escape", "../outside", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.ValidateLinkPath(tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ValidateLinkPath(%q) = %q, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateLinkPath(%q) failed: %v", tt.path, err)
+			}
+			if want := filepath.Join(root, tt.want); got != want {
+				t.Errorf("ValidateLinkPath(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+// TestRemoveAllLink checks that removing a validated link leaves its target alone
+func TestRemoveAllLink(t *testing.T) {
+	v, root, _ := testTree(t)
+	symlink(t, root, "sub", "dirlink")
+
+	path, err := v.ValidateWriteLinkPath("dirlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.RemoveAll(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "dirlink")); !os.IsNotExist(err) {
+		t.Errorf("link still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sub", "f.txt")); err != nil {
+		t.Errorf("link target was removed: %v", err)
+	}
+}
+
+// TestNestedRoot checks that a directory holding another allowed directory
+// is neither renamed nor removed, as the outer handle would bypass the inner
+// directory's read-only mode
+func TestNestedRoot(t *testing.T) {
+	_, root, _ := testTree(t)
+	vendor := filepath.Join(root, "sub", "vendor")
+	if err := os.Mkdir(vendor, 0755); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidator(RootSpec{Dir: root}, RootSpec{Dir: vendor, Mode: ReadOnly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	sub := filepath.Join(root, "sub")
+	if err := v.Rename(sub, filepath.Join(root, "moved")); err == nil {
+		t.Error("renaming a directory holding a read-only directory succeeded")
+	}
+	if err := v.RemoveAll(context.Background(), sub); err == nil {
+		t.Error("removing a directory holding a read-only directory succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(sub, "f.txt")); err != nil {
+		t.Errorf("directory was changed: %v", err)
+	}
+	if _, err := os.Stat(vendor); err != nil {
+		t.Errorf("read-only directory was changed: %v", err)
+	}
+
+	// Other entries next to the read-only directory are not affected
+	f := filepath.Join(sub, "f.txt")
+	if err := v.Rename(f, filepath.Join(root, "f.txt")); err != nil {
+		t.Errorf("renaming a sibling failed: %v", err)
+	}
+	if err := v.RemoveAll(context.Background(), filepath.Join(root, "f.txt")); err != nil {
+		t.Errorf("removing a sibling failed: %v", err)
+	}
+}
+
+// TestSwappedSymlink replaces a validated directory with a link out of the
+// allowed directory before the path is used, which must fail
+func TestSwappedSymlink(t *testing.T) {
+	v, root, outside := testTree(t)
+
+	readPath, err := v.ValidatePath("sub/secret.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePath, err := v.ValidateWritePath("sub/planted.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, root, outside, "sub")
+
+	if data, err := v.ReadFile(context.Background(), readPath); err == nil {
+		t.Errorf("ReadFile through swapped link read %q", data)
+	}
+	if err := v.WriteFile(writePath, []byte("x"), 0644); err == nil {
+		t.Errorf("WriteFile through swapped link succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "planted.txt")); err == nil {
+		t.Errorf("file was written outside the allowed directory")
+	}
+}
+
+// TestSymlinkRace flips a directory between a real directory and a link out
+// of the allowed directory while reading through it
+func TestSymlinkRace(t *testing.T) {
+	v, root, outside := testTree(t)
+	if err := os.WriteFile(filepath.Join(root, "sub", "secret.txt"), []byte("inside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "real")); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, root, "real", "inlink")
+	symlink(t, root, outside, "outlink")
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Atomically point sub at either directory
+		for i := 0; !stop.Load(); i++ {
+			link := "inlink"
+			if i%2 == 1 {
+				link = "outlink"
+			}
+			tmp := filepath.Join(root, "tmp")
+			os.Remove(tmp)
+			if err := os.Symlink(link, tmp); err == nil {
+				os.Rename(tmp, filepath.Join(root, "sub"))
+			}
+		}
+	}()
+
+	for i := 0; i < 2000; i++ {
+		path, err := v.ValidatePath("sub/secret.txt")
+		if err != nil {
+			continue
+		}
+		data, err := v.ReadFile(context.Background(), path)
+		if err == nil && strings.Contains(string(data), "secret") {
+			stop.Store(true)
+			wg.Wait()
+			t.Fatalf("read %s outside the allowed directory", path)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+}
+
+// TestSiblingPrefix checks that a directory whose name extends the allowed
+// directory's is not taken to be inside it
+func TestSiblingPrefix(t *testing.T) {
+	v, root, _ := testTree(t)
+	evil := root + "-evil"
+	if err := os.MkdirAll(evil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, root, evil, "evillink")
+
+	for _, path := range []string{
+		evil,
+		filepath.Join(evil, "x.txt"),
+		"../" + filepath.Base(evil) + "/x.txt",
+		"evillink/x.txt",
+	} {
+		if got, err := v.ValidatePath(path); err == nil {
+			t.Errorf("ValidatePath(%q) = %q, want an error", path, got)
+		}
+	}
+}
+
+// TestRelativeDir checks that an allowed directory given as a relative path
+// is made absolute, so absolute and relative inputs are compared alike
+func TestRelativeDir(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(base, "my-files", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(base, "my-files-evil"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(base)
+
+	v, err := NewValidator(RootSpec{Dir: "./my-files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	root := filepath.Join(base, "my-files")
+	if got := v.GetBaseDir(); got != root {
+		t.Fatalf("GetBaseDir() = %q, want %q", got, root)
+	}
+	for path, want := range map[string]string{
+		"sub":                          filepath.Join(root, "sub"),
+		filepath.Join(root, "sub"):     filepath.Join(root, "sub"),
+		"./sub/../sub/new.txt":         filepath.Join(root, "sub", "new.txt"),
+		filepath.Join(root, "x.txt"):   filepath.Join(root, "x.txt"),
+		"../my-files/sub":              filepath.Join(root, "sub"),
+		"../my-files-evil/x.txt":       "",
+		filepath.Join(base, "x.txt"):   "",
+		"my-files/../../my-files-evil": "",
+	} {
+		got, err := v.ValidatePath(path)
+		switch {
+		case want == "" && err == nil:
+			t.Errorf("ValidatePath(%q) = %q, want an error", path, got)
+		case want != "" && err != nil:
+			t.Errorf("ValidatePath(%q) failed: %v", path, err)
+		case want != "" && got != want:
+			t.Errorf("ValidatePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// realPath resolves the symlinks of the longest existing prefix of path
+func realPath(path string) (string, error) {
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// FuzzValidatePath checks that no path ValidatePath accepts resolves outside
+// the allowed directory, whatever links and dot dots it goes through
+func FuzzValidatePath(f *testing.F) {
+	for _, seed := range []string{
+		"sub/f.txt", ".", "..", "../outside/secret.txt", "/", "inlink/f.txt", "outlink/secret.txt",
+		"chain/f.txt", "dangling", "dangling/x", "sub/../../outside", "nodir/../../outside",
+		"inlink/../outlink", "loop/x", "//sub//f.txt", "sub/./f.txt/..", "rootlink/../outside",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		v, root, outside := testTree(t)
+		symlink(t, root, "sub", "inlink")
+		symlink(t, root, outside, "outlink")
+		symlink(t, root, "inlink", "chain")
+		symlink(t, root, "../outside/new", "dangling")
+		symlink(t, root, "loop", "loop")
+		symlink(t, root, ".", "rootlink")
+
+		got, err := v.ValidatePath(path)
+		if err != nil {
+			return
+		}
+		real, err := realPath(got)
+		if err != nil {
+			t.Fatalf("resolving %q: %v", got, err)
+		}
+		rel, err := filepath.Rel(root, real)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			t.Fatalf("ValidatePath(%q) = %q, which resolves to %q outside %q", path, got, real, root)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+// Package protocol holds the MCP protocol revisions the servers speak and the
+// revisions that introduced features they only use with clients that
+// negotiated them.
+package protocol
+
+import "slices"
+
+// Versions lists the MCP revisions the servers speak, newest first
+var Versions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Revisions that introduced features the servers only use with clients that
+// negotiated them. Revisions are dates, so they compare as strings.
+const (
+	// ToolAnnotations added tool annotations such as titles and readOnlyHint
+	ToolAnnotations = "2025-03-26"
+
+	// StructuredOutput added outputSchema and structuredContent on tools
+	StructuredOutput = "2025-06-18"
+	// Elicitation added elicitation/create requests asking the user for
+	// input. The servers never send them; one that does must also check
+	// that the client declared the elicitation capability.
+	Elicitation = "2025-06-18"
+)
+
+// Negotiate picks the version to use with a client that requested the given
+// one: that version if supported, and otherwise the latest, which the client
+// may reject by disconnecting
+func Negotiate(requested string) string {
+	if slices.Contains(Versions, requested) {
+		return requested
+	}
+	return Versions[0]
+}
+
+// Supports reports whether the negotiated version includes the features
+// introduced in the given revision. Nothing is supported before a version
+// was negotiated.
+func Supports(negotiated, revision string) bool {
+	return negotiated != "" && negotiated >= revision
+}
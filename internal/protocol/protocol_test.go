@@ -0,0 +1,47 @@
+package protocol
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"2024-11-05", "2024-11-05"},
+		{"2025-03-26", "2025-03-26"},
+		{"2025-06-18", "2025-06-18"},
+		{"2099-01-01", "2025-06-18"},
+		{"2024-10-07", "2025-06-18"},
+		{"", "2025-06-18"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.requested); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	tests := []struct {
+		negotiated  string
+		annotations bool
+		structured  bool
+		elicitation bool
+	}{
+		{"", false, false, false},
+		{"2024-11-05", false, false, false},
+		{"2025-03-26", true, false, false},
+		{"2025-06-18", true, true, true},
+	}
+	for _, tt := range tests {
+		if got := Supports(tt.negotiated, ToolAnnotations); got != tt.annotations {
+			t.Errorf("Supports(%q, ToolAnnotations) = %v, want %v", tt.negotiated, got, tt.annotations)
+		}
+		if got := Supports(tt.negotiated, StructuredOutput); got != tt.structured {
+			t.Errorf("Supports(%q, StructuredOutput) = %v, want %v", tt.negotiated, got, tt.structured)
+		}
+		if got := Supports(tt.negotiated, Elicitation); got != tt.elicitation {
+			t.Errorf("Supports(%q, Elicitation) = %v, want %v", tt.negotiated, got, tt.elicitation)
+		}
+	}
+}
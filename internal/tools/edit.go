@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+type textEdit struct {
+	OldText string `json:"oldText"`
+	NewText string `json:"newText"`
+}
+
+type editFileArgs struct {
+	Path             string     `json:"path"`
+	Edits            []textEdit `json:"edits"`
+	DryRun           bool       `json:"dryRun"`
+	IgnoreWhitespace bool       `json:"ignoreWhitespace"`
+}
+
+func (t *toolset) editFile() Tool {
+	const name = "edit_file"
+	return Tool{
+		Name: name,
+		Description: "Make targeted edits to a text file. Each edit replaces oldText, which must occur exactly once, " +
+			"with newText; edits apply in order and the file is only written if all of them succeed. Returns a " +
+			"unified diff of the change. Set dryRun to preview the diff without writing.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path": stringProperty("Path to the file to edit"),
+				"edits": map[string]interface{}{
+					"type":        "array",
+					"description": "Edits to apply in order",
+					"minItems":    1,
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"oldText": stringProperty("Text to replace; must match exactly one place in the file"),
+							"newText": stringProperty("Text to put in its place"),
+						},
+						"required": []string{"oldText", "newText"},
+					},
+				},
+				"dryRun":           booleanProperty("Return the diff without writing the file"),
+				"ignoreWhitespace": booleanProperty("When oldText does not match exactly, match whole lines ignoring differences in indentation and spacing"),
+			},
+			Required: []string{"path", "edits"},
+		},
+		Annotations: Annotations{Title: "Edit File", Destructive: true},
+		Handler: Typed(func(ctx context.Context, args editFileArgs) (*Result, error) {
+			if len(args.Edits) == 0 {
+				return nil, &ArgumentError{Message: "Invalid parameter edits: must list at least one edit"}
+			}
+			for i, edit := range args.Edits {
+				if edit.OldText == "" {
+					return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameter edits: oldText of edit %d is empty", i+1)}
+				}
+			}
+
+			validPath, err := t.resolvePath(ctx, name, args.Path, writePath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			data, err := t.v.ReadFile(ctx, validPath)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error reading file: %v", err)), nil
+			}
+			if !filesystem.IsText(data) {
+				return ErrorResult(fmt.Sprintf("%s is not a text file", validPath)), nil
+			}
+
+			before := string(data)
+			after := before
+			for i, edit := range args.Edits {
+				after, err = applyEdit(after, edit, args.IgnoreWhitespace)
+				if err != nil {
+					return ErrorResult(fmt.Sprintf("Edit %d of %d failed, nothing was written: %v", i+1, len(args.Edits), err)), nil
+				}
+			}
+
+			diff := unifiedDiff(args.Path, before, after)
+			if diff == "" {
+				return TextResult(fmt.Sprintf("No changes: the edits leave %s as it is", validPath)), nil
+			}
+			if args.DryRun {
+				return TextResult(fmt.Sprintf("Dry run, nothing was written. The edits would change %s:\n%s", validPath, diff)), nil
+			}
+
+			if err := t.v.ReplaceFile(validPath, []byte(after)); err != nil {
+				return ErrorResult(fmt.Sprintf("Error writing file: %v", err)), nil
+			}
+			return TextResult(fmt.Sprintf("Successfully edited %s:\n%s", validPath, diff)), nil
+		}),
+	}
+}
+
+// applyEdit replaces the single occurrence of the edit's oldText in content.
+// With ignoreWhitespace, an oldText that does not occur exactly is matched
+// against whole lines compared without regard to whitespace, and newText is
+// reindented to match the lines it replaces and takes their line endings.
+func applyEdit(content string, edit textEdit, ignoreWhitespace bool) (string, error) {
+	switch n := countMatches(content, edit.OldText); {
+	case n == 1:
+		return strings.Replace(content, edit.OldText, edit.NewText, 1), nil
+	case n > 1:
+		return "", fmt.Errorf("oldText matches %d places; include more surrounding text to make it unique", n)
+	case !ignoreWhitespace:
+		return "", fmt.Errorf("oldText was not found")
+	}
+
+	lines := splitLines(content)
+	oldLines := strings.Split(strings.TrimSuffix(edit.OldText, "\n"), "\n")
+
+	var matches []int
+	for i := 0; i+len(oldLines) <= len(lines); i++ {
+		matched := true
+		for j, oldLine := range oldLines {
+			if normalizeSpace(lines[i+j]) != normalizeSpace(oldLine) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("oldText was not found, even ignoring whitespace")
+	case 1:
+	default:
+		return "", fmt.Errorf("oldText matches %d places ignoring whitespace; include more surrounding lines to make it unique", len(matches))
+	}
+
+	// Lines of newText indented like a line of oldText take the indentation
+	// of the file's line matched by it; others are shifted like the first
+	first := matches[0]
+	indents := make(map[string]string)
+	for j, oldLine := range oldLines {
+		if _, ok := indents[leadingSpace(oldLine)]; !ok {
+			indents[leadingSpace(oldLine)] = leadingSpace(lines[first+j])
+		}
+	}
+	oldIndent := leadingSpace(oldLines[0])
+	newText := strings.TrimSuffix(strings.ReplaceAll(edit.NewText, "\r\n", "\n"), "\n")
+	newLines := strings.Split(newText, "\n")
+	for i, line := range newLines {
+		indent := leadingSpace(line)
+		if fileIndent, ok := indents[indent]; ok && line != "" {
+			newLines[i] = fileIndent + line[len(indent):]
+		} else if line != "" && strings.HasPrefix(line, oldIndent) {
+			newLines[i] = indents[oldIndent] + line[len(oldIndent):]
+		}
+	}
+	eol := "\n"
+	if strings.HasSuffix(lines[first], "\r\n") {
+		eol = "\r\n"
+	}
+	replacement := strings.Join(newLines, eol)
+
+	// The replacement ends like the matched lines, in a line break unless
+	// they end the file without one
+	if strings.HasSuffix(lines[first+len(oldLines)-1], "\n") {
+		replacement += eol
+	}
+
+	return strings.Join(lines[:first], "") + replacement + strings.Join(lines[first+len(oldLines):], ""), nil
+}
+
+// countMatches counts the occurrences of text in content, including ones
+// that overlap, as any of them could be the one meant
+func countMatches(content, text string) int {
+	n := 0
+	for i := 0; ; i++ {
+		j := strings.Index(content[i:], text)
+		if j < 0 {
+			return n
+		}
+		n++
+		i += j
+	}
+}
+
+// normalizeSpace collapses runs of whitespace and trims the ends of s
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// leadingSpace returns the indentation of line
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
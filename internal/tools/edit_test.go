@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyEdit(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		edit             textEdit
+		ignoreWhitespace bool
+		want             string
+		wantErr          string
+	}{
+		{
+			name:    "single match",
+			content: "a := 1\nb := 2\n",
+			edit:    textEdit{OldText: "b := 2", NewText: "b := 3"},
+			want:    "a := 1\nb := 3\n",
+		},
+		{
+			name:    "two matches",
+			content: "stop()\nstop()\n",
+			edit:    textEdit{OldText: "stop()", NewText: "halt()"},
+			wantErr: "oldText matches 2 places",
+		},
+		{
+			name:    "overlapping matches",
+			content: "aaa",
+			edit:    textEdit{OldText: "aa", NewText: "b"},
+			wantErr: "oldText matches 2 places",
+		},
+		{
+			name:    "overlapping lines",
+			content: "}\n}\n}\n",
+			edit:    textEdit{OldText: "}\n}", NewText: "}"},
+			wantErr: "oldText matches 2 places",
+		},
+		{
+			name:    "not found",
+			content: "a\n",
+			edit:    textEdit{OldText: "b", NewText: "c"},
+			wantErr: "oldText was not found",
+		},
+		{
+			name:             "reindented",
+			content:          "func f() {\n\tif ok {\n\t\tstart()\n\t}\n}\n",
+			edit:             textEdit{OldText: "if ok {\n    start()\n}", NewText: "if ok {\n    run()\n    wait()\n}"},
+			ignoreWhitespace: true,
+			want:             "func f() {\n\tif ok {\n\t\trun()\n\t\twait()\n\t}\n}\n",
+		},
+		{
+			name:             "CRLF line endings",
+			content:          "func f() {\r\n\tif ok {\r\n\t\tstart()\r\n\t}\r\n}\r\n",
+			edit:             textEdit{OldText: "if ok {\n    start()\n}", NewText: "if ok {\n    run()\n    wait()\n}"},
+			ignoreWhitespace: true,
+			want:             "func f() {\r\n\tif ok {\r\n\t\trun()\r\n\t\twait()\r\n\t}\r\n}\r\n",
+		},
+		{
+			name:    "exact match keeps newText as given",
+			content: "a\r\n  b\r\n",
+			edit:    textEdit{OldText: "b", NewText: "c\r\nd"},
+			want:    "a\r\n  c\r\nd\r\n",
+		},
+		{
+			name:             "CRLF newText ignoring whitespace",
+			content:          "a\r\n  b  c\r\n",
+			edit:             textEdit{OldText: "b c", NewText: "d\r\ne"},
+			ignoreWhitespace: true,
+			want:             "a\r\n  d\r\n  e\r\n",
+		},
+		{
+			name:             "last line without a line break",
+			content:          "a\n  b  c",
+			edit:             textEdit{OldText: "b c\n", NewText: "d\n"},
+			ignoreWhitespace: true,
+			want:             "a\n  d",
+		},
+		{
+			name:             "newText without a line break",
+			content:          "a\n  b  c\nd\n",
+			edit:             textEdit{OldText: "b c", NewText: "e"},
+			ignoreWhitespace: true,
+			want:             "a\n  e\nd\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyEdit(tt.content, tt.edit, tt.ignoreWhitespace)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountMatches(t *testing.T) {
+	tests := []struct {
+		content, text string
+		want          int
+	}{
+		{"", "a", 0},
+		{"abc", "b", 1},
+		{"aaaa", "aa", 3},
+		{"abab", "ab", 2},
+		{"ababa", "aba", 2},
+	}
+	for _, tt := range tests {
+		if got := countMatches(tt.content, tt.text); got != tt.want {
+			t.Errorf("countMatches(%q, %q) = %d, want %d", tt.content, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// Options configures the filesystem tools
+type Options struct {
+	// HonorIgnoreFiles hides paths matched by .gitignore and .mcpignore
+	// files from list_directory unless a call sets includeIgnored
+	HonorIgnoreFiles bool
+
+	// MaxReadBytes caps the content read_file and read_multiple_files
+	// return in one call; longer content is cut short with a note on how to
+	// read the rest
+	MaxReadBytes int64
+}
+
+// DefaultMaxReadBytes is the default for Options.MaxReadBytes
+const DefaultMaxReadBytes = 256 << 10
+
+// toolset holds what the filesystem tools share
+type toolset struct {
+	v    *filesystem.Validator
+	opts Options
+}
+
+// pathUse says what a tool does with a path it was given, which decides how
+// the path is validated and authorized
+type pathUse int
+
+const (
+	// readPath reads what the path names, following symlinks
+	readPath pathUse = iota
+	// writePath creates or changes what the path names, following symlinks
+	writePath
+	// removePath removes the path and everything below it; a symlink is
+	// removed itself rather than its target
+	removePath
+)
+
+// resolvePath validates a path given to the tool name for the given use and
+// authorizes the tool on it. It returns the path to operate on; errors are
+// meant for the tool's error result.
+func (t *toolset) resolvePath(ctx context.Context, name, path string, use pathUse) (string, error) {
+	var validPath string
+	var err error
+	switch use {
+	case readPath:
+		validPath, err = t.v.ValidatePath(path)
+	case writePath:
+		validPath, err = t.v.ValidateWritePath(path)
+	case removePath:
+		validPath, err = t.v.ValidateWriteLinkPath(path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if use == removePath {
+		err = t.v.AuthorizeTree(ctx, name, validPath)
+	} else {
+		err = t.v.Authorize(name, validPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	return validPath, nil
+}
+
+// NewFilesystem returns a registry with the filesystem tools operating on
+// the allowed directories of v
+func NewFilesystem(v *filesystem.Validator, opts Options) *Registry {
+	if opts.MaxReadBytes <= 0 {
+		opts.MaxReadBytes = DefaultMaxReadBytes
+	}
+	t := &toolset{v: v, opts: opts}
+
+	r := NewRegistry()
+	r.Register(t.readFile())
+	r.Register(t.readMultipleFiles())
+	r.Register(t.writeFile())
+	r.Register(t.editFile())
+	r.Register(t.applyPatch())
+	r.Register(t.moveFile())
+	r.Register(t.copyFile())
+	r.Register(t.listDirectory())
+	r.Register(t.directoryTree())
+	r.Register(t.searchFiles())
+	r.Register(t.grepFiles())
+	r.Register(t.createDirectory())
+	r.Register(t.deleteFile())
+	r.Register(t.listAllowedDirectories())
+	return r
+}
+
+// stringProperty describes a string parameter in an input schema
+func stringProperty(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": description,
+	}
+}
+
+// integerProperty describes an integer parameter in an input schema
+func integerProperty(description string, minimum int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "integer",
+		"description": description,
+		"minimum":     minimum,
+	}
+}
+
+// booleanProperty describes a boolean parameter in an input schema
+func booleanProperty(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "boolean",
+		"description": description,
+	}
+}
+
+type pathArgs struct {
+	Path string `json:"path"`
+}
+
+type writeFileArgs struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+func (t *toolset) writeFile() Tool {
+	const name = "write_file"
+	return Tool{
+		Name:        name,
+		Description: "Write content to a file (overwrites existing content)",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path":    stringProperty("Path to the file to write"),
+				"content": stringProperty("Content to write to the file"),
+			},
+			Required: []string{"path", "content"},
+		},
+		Annotations: Annotations{Title: "Write File", Destructive: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args writeFileArgs) (*Result, error) {
+			validPath, err := t.resolvePath(ctx, name, args.Path, writePath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			err = t.v.MkdirAll(filepath.Dir(validPath), 0755)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error creating directory: %v", err)), nil
+			}
+
+			err = t.v.WriteFile(validPath, []byte(args.Content), 0644)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error writing file: %v", err)), nil
+			}
+
+			return TextResult(fmt.Sprintf("Successfully wrote to file: %s", validPath)), nil
+		}),
+	}
+}
+
+type listDirectoryArgs struct {
+	Path           string `json:"path"`
+	IncludeIgnored bool   `json:"includeIgnored"`
+}
+
+func (t *toolset) listDirectory() Tool {
+	const name = "list_directory"
+	return Tool{
+		Name:        name,
+		Description: "List the contents of a directory",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path":           stringProperty("Path to the directory to list"),
+				"includeIgnored": booleanProperty("Include entries matched by .gitignore and .mcpignore files"),
+			},
+			Required: []string{"path"},
+		},
+		Annotations: Annotations{Title: "List Directory", ReadOnly: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args listDirectoryArgs) (*Result, error) {
+			validPath, err := t.resolvePath(ctx, name, args.Path, readPath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			files, err := t.v.ReadDir(validPath)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error reading directory: %v", err)), nil
+			}
+
+			var ignore *filesystem.IgnoreFilter
+			if t.opts.HonorIgnoreFiles && !args.IncludeIgnored {
+				ignore = t.v.NewIgnoreFilter()
+			}
+
+			var fileList []string
+			for _, file := range files {
+				if ignore != nil && ignore.Ignored(filepath.Join(validPath, file.Name()), file.IsDir()) {
+					continue
+				}
+				if file.IsDir() {
+					fileList = append(fileList, file.Name()+"/")
+				} else {
+					fileList = append(fileList, file.Name())
+				}
+			}
+
+			return TextResult(fmt.Sprintf("Directory contents:\n%s", strings.Join(fileList, "\n"))), nil
+		}),
+	}
+}
+
+func (t *toolset) createDirectory() Tool {
+	const name = "create_directory"
+	return Tool{
+		Name:        name,
+		Description: "Create a new directory",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path": stringProperty("Path to the directory to create"),
+			},
+			Required: []string{"path"},
+		},
+		Annotations: Annotations{Title: "Create Directory", Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args pathArgs) (*Result, error) {
+			validPath, err := t.resolvePath(ctx, name, args.Path, writePath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			err = t.v.MkdirAll(validPath, 0755)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error creating directory: %v", err)), nil
+			}
+
+			return TextResult(fmt.Sprintf("Successfully created directory: %s", validPath)), nil
+		}),
+	}
+}
+
+func (t *toolset) deleteFile() Tool {
+	const name = "delete_file"
+	return Tool{
+		Name:        name,
+		Description: "Delete a file or directory",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path": stringProperty("Path to the file or directory to delete"),
+			},
+			Required: []string{"path"},
+		},
+		Annotations: Annotations{Title: "Delete File", Destructive: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args pathArgs) (*Result, error) {
+			validPath, err := t.resolvePath(ctx, name, args.Path, removePath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			err = t.v.RemoveAll(ctx, validPath)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error deleting file/directory: %v", err)), nil
+			}
+
+			return TextResult(fmt.Sprintf("Successfully deleted: %s", validPath)), nil
+		}),
+	}
+}
+
+type AllowedDirectory struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+type AllowedDirectoriesResult struct {
+	Directories []AllowedDirectory `json:"directories"`
+}
+
+func (t *toolset) listAllowedDirectories() Tool {
+	return Tool{
+		Name:        "list_allowed_directories",
+		Description: "List the directories this server is allowed to access and whether each is read-only or read-write",
+		InputSchema: Schema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+		OutputSchema: &Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"directories": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"name": map[string]interface{}{"type": "string"},
+							"path": map[string]interface{}{"type": "string"},
+							"mode": map[string]interface{}{"type": "string", "enum": []string{"read-write", "read-only"}},
+						},
+						"required": []string{"name", "path", "mode"},
+					},
+				},
+			},
+			Required: []string{"directories"},
+		},
+		Annotations: Annotations{Title: "List Allowed Directories", ReadOnly: true, Idempotent: true},
+		Handler: func(ctx context.Context, arguments map[string]interface{}) (*Result, error) {
+			var dirList []string
+			var directories []AllowedDirectory
+			for _, root := range t.v.Roots() {
+				dirList = append(dirList, fmt.Sprintf("%s: %s (%s)", root.Name, root.Dir, root.Mode))
+				directories = append(directories, AllowedDirectory{Name: root.Name, Path: root.Dir, Mode: root.Mode.String()})
+			}
+
+			result := TextResult(fmt.Sprintf("Allowed directories:\n%s", strings.Join(dirList, "\n")))
+			result.Structured = AllowedDirectoriesResult{Directories: directories}
+			return result, nil
+		},
+	}
+}
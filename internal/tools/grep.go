@@ -0,0 +1,338 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// defaultMaxMatches is the number of matching lines grep_files returns
+// unless a call asks for another
+const defaultMaxMatches = 200
+
+// maxGrepLineLength is the length at which grep_files cuts the lines it
+// shows, so that a match in a minified file does not fill the context
+const maxGrepLineLength = 500
+
+type grepFilesArgs struct {
+	Pattern           string   `json:"pattern"`
+	Path              string   `json:"path"`
+	Literal           bool     `json:"literal"`
+	CaseInsensitive   bool     `json:"caseInsensitive"`
+	IncludePatterns   []string `json:"includePatterns"`
+	ExcludePatterns   []string `json:"excludePatterns"`
+	ContextBefore     int      `json:"contextBefore"`
+	ContextAfter      int      `json:"contextAfter"`
+	MaxMatchesPerFile int      `json:"maxMatchesPerFile"`
+	MaxResults        int      `json:"maxResults"`
+	IncludeIgnored    bool     `json:"includeIgnored"`
+}
+
+// validate rejects arguments that are out of range or malformed
+func (args grepFilesArgs) validate() error {
+	for _, pattern := range append(append([]string(nil), args.IncludePatterns...), args.ExcludePatterns...) {
+		if err := filesystem.ValidateGlob(pattern); err != nil {
+			return &ArgumentError{Message: fmt.Sprintf("Invalid file pattern: %v", err)}
+		}
+	}
+	switch {
+	case args.Pattern == "":
+		return &ArgumentError{Message: "Invalid parameter pattern: must not be empty"}
+	case args.ContextBefore < 0:
+		return &ArgumentError{Message: "Invalid parameter contextBefore: must not be negative"}
+	case args.ContextAfter < 0:
+		return &ArgumentError{Message: "Invalid parameter contextAfter: must not be negative"}
+	case args.MaxMatchesPerFile < 0:
+		return &ArgumentError{Message: "Invalid parameter maxMatchesPerFile: must be at least 1"}
+	case args.MaxResults < 0:
+		return &ArgumentError{Message: "Invalid parameter maxResults: must be at least 1"}
+	}
+	return nil
+}
+
+// regexp compiles the query of args
+func (args grepFilesArgs) regexp() (*regexp.Regexp, error) {
+	expr := args.Pattern
+	if args.Literal {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if args.CaseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameter pattern: %v", err)}
+	}
+	return re, nil
+}
+
+func (t *toolset) grepFiles() Tool {
+	const name = "grep_files"
+	return Tool{
+		Name: name,
+		Description: "Search the contents of text files for a regular expression (RE2 syntax) or, with literal, a " +
+			"plain string. Searches the files below path, the first allowed directory by default, skipping binary " +
+			"files. Matches are grouped by file: N: marks a matching line N and N- a context line, and -- separates " +
+			"groups that are not adjacent.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"pattern":         stringProperty("Regular expression to search for, or the exact text with literal"),
+				"path":            stringProperty("File or directory to search (default the first allowed directory)"),
+				"literal":         booleanProperty("Search for pattern as plain text instead of a regular expression"),
+				"caseInsensitive": booleanProperty("Ignore case when matching"),
+				"includePatterns": map[string]interface{}{
+					"type": "array",
+					"description": "Only search files matching one of these glob patterns; a pattern without / matches " +
+						"the file name, such as *.go, and one with / the path relative to path",
+					"items": map[string]interface{}{"type": "string"},
+				},
+				"excludePatterns": map[string]interface{}{
+					"type":        "array",
+					"description": "Glob patterns, like includePatterns, of files and directories not to search",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+				"contextBefore":     integerProperty("Number of lines to show before each match", 0),
+				"contextAfter":      integerProperty("Number of lines to show after each match", 0),
+				"maxMatchesPerFile": integerProperty("Maximum number of matching lines to show per file (default unlimited)", 1),
+				"maxResults":        integerProperty(fmt.Sprintf("Maximum number of matching lines to show in all (default %d)", defaultMaxMatches), 1),
+				"includeIgnored":    booleanProperty("Also search files matched by .gitignore and .mcpignore files"),
+			},
+			Required: []string{"pattern"},
+		},
+		Annotations: Annotations{Title: "Grep Files", ReadOnly: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args grepFilesArgs) (*Result, error) {
+			if err := args.validate(); err != nil {
+				return nil, err
+			}
+			re, err := args.regexp()
+			if err != nil {
+				return nil, err
+			}
+			if args.MaxResults == 0 {
+				args.MaxResults = defaultMaxMatches
+			}
+			if args.Path == "" {
+				args.Path = t.v.GetBaseDir()
+			}
+
+			validPath, err := t.resolvePath(ctx, name, args.Path, readPath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			info, err := t.v.Stat(validPath)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error reading %s: %v", validPath, err)), nil
+			}
+
+			g := &grep{t: t, re: re, args: args, left: args.MaxResults}
+
+			if !info.IsDir() {
+				// Progress is reported in bytes scanned
+				if err := g.file(ctx, validPath, filepath.Base(validPath)); err != nil {
+					return ErrorResult(fmt.Sprintf("Error reading file: %v", err)), nil
+				}
+				return TextResult(g.result(validPath)), nil
+			}
+
+			var ignore *filesystem.IgnoreFilter
+			if t.opts.HonorIgnoreFiles && !args.IncludeIgnored {
+				ignore = t.v.NewIgnoreFilter()
+			}
+
+			// Progress is reported in files scanned, as the progress of the
+			// individual files would restart with each one
+			progress := filesystem.TrackProgress(ctx, 0)
+			defer progress.Finish()
+			scanCtx := filesystem.WithProgress(ctx, nil)
+
+			err = t.v.WalkDir(ctx, validPath, func(path string, d fs.DirEntry, err error) error {
+				if path == validPath {
+					return err
+				}
+				if err != nil {
+					// Unreadable entries are left out rather than failing the search
+					if d != nil && d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+
+				rel, err := filepath.Rel(validPath, path)
+				if err != nil {
+					return err
+				}
+				rel = filepath.ToSlash(rel)
+
+				if matchesFile(rel, args.ExcludePatterns) ||
+					(ignore != nil && ignore.Ignored(path, d.IsDir())) ||
+					t.v.Authorize(name, path) != nil {
+					if d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+				if !d.Type().IsRegular() || (len(args.IncludePatterns) > 0 && !matchesFile(rel, args.IncludePatterns)) {
+					return nil
+				}
+
+				err = g.file(scanCtx, path, rel)
+				progress.Add(1)
+				if err != nil {
+					// A file that cannot be read is skipped like one the walk cannot open
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					return nil
+				}
+				if g.truncated {
+					return fs.SkipAll
+				}
+				return nil
+			})
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error searching directory: %v", err)), nil
+			}
+
+			return TextResult(g.result(validPath)), nil
+		}),
+	}
+}
+
+// matchesFile reports whether a relative path matches any of the patterns,
+// comparing patterns without a slash with its last component
+func matchesFile(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(strings.Trim(pattern, "/"), "/") {
+			name = path.Base(rel)
+		}
+		if filesystem.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// grep collects the matches of one grep_files call
+type grep struct {
+	t    *toolset
+	re   *regexp.Regexp
+	args grepFilesArgs
+
+	out     strings.Builder
+	files   int
+	matches int
+	// left is the number of matches still to show; truncated is set once
+	// a match beyond that is found
+	left      int
+	truncated bool
+}
+
+// grepLine is a line of a file kept as context
+type grepLine struct {
+	n    int64
+	text string
+}
+
+// file searches one file, shown under label, and appends its matches
+func (g *grep) file(ctx context.Context, path, label string) error {
+	var out strings.Builder
+	var before []grepLine
+	var lineNo, last int64
+	matches, after := 0, 0
+	binary, more := false, false
+
+	write := func(n int64, sep byte, text string) {
+		if last > 0 && n > last+1 {
+			out.WriteString("--\n")
+		}
+		fmt.Fprintf(&out, "%d%c%s\n", n, sep, text)
+		last = n
+	}
+
+	err := g.t.v.ScanLines(ctx, path, int(g.t.opts.MaxReadBytes), func(line []byte, cut bool) bool {
+		lineNo++
+		if bytes.IndexByte(line, 0) >= 0 {
+			binary = true
+			return false
+		}
+
+		if !g.re.Match(line) {
+			if after > 0 {
+				write(lineNo, '-', grepText(line))
+				after--
+			} else if g.args.ContextBefore > 0 {
+				before = append(before, grepLine{lineNo, grepText(line)})
+				if len(before) > g.args.ContextBefore {
+					before = before[1:]
+				}
+			}
+			return true
+		}
+
+		if matches == g.left || (g.args.MaxMatchesPerFile > 0 && matches == g.args.MaxMatchesPerFile) {
+			more = true
+			return false
+		}
+		for _, context := range before {
+			write(context.n, '-', context.text)
+		}
+		before = before[:0]
+		write(lineNo, ':', grepText(line))
+		matches++
+		after = g.args.ContextAfter
+		return true
+	})
+	if err != nil || binary {
+		return err
+	}
+	if more && matches == g.left {
+		g.truncated = true
+	}
+	if matches == 0 {
+		return nil
+	}
+
+	if g.files > 0 {
+		g.out.WriteString("\n")
+	}
+	g.out.WriteString(label + "\n")
+	g.out.WriteString(out.String())
+	if more && matches < g.left {
+		fmt.Fprintf(&g.out, "[Only the first %d matches in this file are shown.]\n", matches)
+	}
+	g.files++
+	g.matches += matches
+	g.left -= matches
+	return nil
+}
+
+// result returns the text of the grep_files result for a search of path
+func (g *grep) result(path string) string {
+	if g.matches == 0 {
+		return fmt.Sprintf("No matches for %s in %s", g.args.Pattern, path)
+	}
+	text := fmt.Sprintf("Found %d matching line(s) in %d file(s):\n\n%s", g.matches, g.files, strings.TrimSuffix(g.out.String(), "\n"))
+	if g.truncated {
+		text += fmt.Sprintf("\n\n[Stopped after %d matches; narrow the search or raise maxResults to see more.]", g.args.MaxResults)
+	}
+	return text
+}
+
+// grepText returns a line as shown in grep_files results
+func grepText(line []byte) string {
+	suffix := ""
+	if len(line) > maxGrepLineLength {
+		line, suffix = trimPartialRune(line[:maxGrepLineLength]), " [...]"
+	}
+	return strings.ToValidUTF8(string(line), "\uFFFD") + suffix
+}
@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// Overwrite policies of move_file and copy_file
+const (
+	overwriteNever   = "never"
+	overwriteReplace = "replace"
+	overwriteSkip    = "skip"
+)
+
+type transferArgs struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Overwrite   string `json:"overwrite"`
+}
+
+// transferSchema is the input schema shared by move_file and copy_file
+func transferSchema(verb string) Schema {
+	return Schema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"source":      stringProperty(fmt.Sprintf("Path of the file or directory to %s", verb)),
+			"destination": stringProperty("Path it should have afterwards; missing parent directories are created"),
+			"overwrite": map[string]interface{}{
+				"type": "string",
+				"description": "What to do when the destination exists: never (default) fails, replace deletes it once the new entry is in place, " +
+					"skip keeps existing files and merges directories",
+				"enum": []string{overwriteNever, overwriteReplace, overwriteSkip},
+			},
+		},
+		Required: []string{"source", "destination"},
+	}
+}
+
+func (t *toolset) moveFile() Tool {
+	const name = "move_file"
+	return Tool{
+		Name: name,
+		Description: "Move or rename a file or directory. Renames in place where possible and otherwise, across " +
+			"filesystems or allowed directories, copies and then deletes the source. Permissions and modification " +
+			"times are kept, and a symlink is moved itself rather than its target.",
+		InputSchema: transferSchema("move"),
+		Annotations: Annotations{Title: "Move File", Destructive: true},
+		Handler: Typed(func(ctx context.Context, args transferArgs) (*Result, error) {
+			return t.transfer(ctx, name, args, true)
+		}),
+	}
+}
+
+func (t *toolset) copyFile() Tool {
+	const name = "copy_file"
+	return Tool{
+		Name: name,
+		Description: "Copy a file or directory, recursively. Permissions and modification times are kept and " +
+			"symlinks are copied as links.",
+		InputSchema: transferSchema("copy"),
+		Annotations: Annotations{Title: "Copy File", Destructive: true},
+		Handler: Typed(func(ctx context.Context, args transferArgs) (*Result, error) {
+			return t.transfer(ctx, name, args, false)
+		}),
+	}
+}
+
+// transfer moves or copies args.Source to args.Destination under the given
+// tool name, applying the overwrite policy
+func (t *toolset) transfer(ctx context.Context, name string, args transferArgs, move bool) (*Result, error) {
+	overwrite := args.Overwrite
+	switch overwrite {
+	case "":
+		overwrite = overwriteNever
+	case overwriteNever, overwriteReplace, overwriteSkip:
+	default:
+		return nil, &ArgumentError{Message: "Invalid parameter overwrite: must be never, replace or skip"}
+	}
+
+	verb, doing, done := "copy", "copying", "copied"
+	if move {
+		verb, doing, done = "move", "moving", "moved"
+	}
+
+	// A symlink given as the source is moved or copied itself
+	var src string
+	var err error
+	if move {
+		src, err = t.v.ValidateWriteLinkPath(args.Source)
+	} else {
+		src, err = t.v.ValidateLinkPath(args.Source)
+	}
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+	dst, err := t.v.ValidateWritePath(args.Destination)
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+
+	switch {
+	case src == dst:
+		return ErrorResult(fmt.Sprintf("Cannot %s %s onto itself", verb, src)), nil
+	case within(dst, src):
+		return ErrorResult(fmt.Sprintf("Cannot %s %s into itself", verb, src)), nil
+	case within(src, dst):
+		return ErrorResult(fmt.Sprintf("Cannot %s %s to %s, which contains it", verb, src, dst)), nil
+	}
+
+	if move {
+		for _, root := range t.v.Roots() {
+			if root.Dir == src {
+				return ErrorResult(fmt.Sprintf("Cannot move allowed directory %s", src)), nil
+			}
+			if within(root.Dir, src) {
+				return ErrorResult(fmt.Sprintf("Cannot move %s, which contains allowed directory %s", src, root.Dir)), nil
+			}
+		}
+	}
+
+	srcInfo, err := t.v.Lstat(src)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error reading source: %v", err)), nil
+	}
+	if err := t.authorizeTransfer(ctx, name, src, dst); err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+
+	dstInfo, err := t.v.Lstat(dst)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ErrorResult(fmt.Sprintf("Error reading destination: %v", err)), nil
+	}
+
+	merge, replace := false, false
+	if exists {
+		switch {
+		case overwrite == overwriteNever:
+			return ErrorResult(fmt.Sprintf("%s already exists; set overwrite to replace or skip to %s anyway", dst, verb)), nil
+		case overwrite == overwriteSkip && srcInfo.IsDir() && dstInfo.IsDir():
+			merge = true
+		case overwrite == overwriteSkip:
+			return TextResult(fmt.Sprintf("Skipped: %s already exists", dst)), nil
+		case move && srcInfo.Mode().IsRegular() && dstInfo.Mode().IsRegular():
+			// Renaming replaces the file atomically
+		default:
+			replace = true
+		}
+	}
+
+	if err := t.v.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return ErrorResult(fmt.Sprintf("Error creating directory: %v", err)), nil
+	}
+
+	var skipped []string
+	copied := false
+	transfer := func() error {
+		var err error
+		if move {
+			if merge {
+				copied, skipped, err = t.mergeMove(ctx, src, dst)
+			} else {
+				copied, err = t.moveEntry(ctx, src, dst)
+			}
+			return err
+		}
+		skipped, err = t.v.Copy(ctx, src, dst, merge)
+		if err != nil && !merge {
+			t.v.RemoveAll(filesystem.WithProgress(ctx, nil), dst)
+		}
+		return err
+	}
+	if replace {
+		err = t.replaceEntry(ctx, dst, transfer)
+	} else {
+		err = transfer()
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error %s: %v", doing, err)), nil
+	}
+
+	message := fmt.Sprintf("Successfully %s %s to %s", done, src, dst)
+	if copied {
+		message += " by copying and deleting, as they are on different filesystems or in different allowed directories"
+	}
+	if len(skipped) > 0 {
+		message += fmt.Sprintf("\nSkipped %d path(s) that already exist at the destination:\n%s", len(skipped), strings.Join(skipped, "\n"))
+	}
+	return TextResult(message), nil
+}
+
+// authorizeTransfer checks the source tree and everything at and below the
+// destination, as it is now and as it will be, against the access policy
+func (t *toolset) authorizeTransfer(ctx context.Context, name, src, dst string) error {
+	if err := t.v.AuthorizeTree(ctx, name, src); err != nil {
+		return err
+	}
+	if err := t.v.Authorize(name, dst); err != nil {
+		return err
+	}
+	if err := t.v.AuthorizeTree(ctx, name, dst); err != nil {
+		return err
+	}
+	return t.v.WalkDir(ctx, src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return t.v.Authorize(name, filepath.Join(dst, rel))
+	})
+}
+
+// moveEntry renames src to dst and falls back to copying and deleting when
+// they are on different filesystems or in different allowed directories,
+// reporting whether it did. A failed copy is removed again, leaving the
+// source in place.
+func (t *toolset) moveEntry(ctx context.Context, src, dst string) (copied bool, err error) {
+	err = t.v.Rename(src, dst)
+	if !errors.Is(err, filesystem.ErrCrossDevice) {
+		return false, err
+	}
+
+	// Progress is reported for the copy, which is the bulk of the work
+	quiet := filesystem.WithProgress(ctx, nil)
+	if _, err := t.v.Copy(ctx, src, dst, false); err != nil {
+		t.v.RemoveAll(quiet, dst)
+		return true, err
+	}
+	if err := t.v.RemoveAll(quiet, src); err != nil {
+		return true, fmt.Errorf("copied to %s but could not delete the source: %v", dst, err)
+	}
+	return true, nil
+}
+
+// replaceEntry moves the existing dst aside to a hidden sibling, calls
+// transfer to put the new entry in its place and only then deletes the old
+// one, so that a failed copy leaves dst as it was
+func (t *toolset) replaceEntry(ctx context.Context, dst string, transfer func() error) error {
+	old := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+"."+rand.Text()+".old")
+	if err := t.v.Rename(dst, old); err != nil {
+		return fmt.Errorf("could not replace %s: %v", dst, err)
+	}
+	if err := transfer(); err != nil {
+		if restoreErr := t.v.Rename(old, dst); restoreErr != nil {
+			return fmt.Errorf("%v; the previous %s is kept at %s", err, dst, old)
+		}
+		return err
+	}
+
+	// The new entry is in place, so the old one goes even if ctx was cancelled
+	if err := t.v.RemoveAll(filesystem.WithProgress(context.WithoutCancel(ctx), nil), old); err != nil {
+		return fmt.Errorf("replaced %s but could not delete its previous content at %s: %v", dst, old, err)
+	}
+	return nil
+}
+
+// mergeMove moves the entries of directory src into the existing directory
+// dst, descending into directories present in both and leaving other
+// existing entries alone. src is removed when nothing is left in it.
+func (t *toolset) mergeMove(ctx context.Context, src, dst string) (copied bool, skipped []string, err error) {
+	entries, err := t.v.ReadDir(src)
+	if err != nil {
+		return false, nil, err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return copied, skipped, err
+		}
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+
+		info, err := t.v.Lstat(to)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return copied, skipped, err
+		}
+		var entryCopied bool
+		var entrySkipped []string
+		switch {
+		case err != nil:
+			entryCopied, err = t.moveEntry(ctx, from, to)
+		case entry.IsDir() && info.IsDir():
+			entryCopied, entrySkipped, err = t.mergeMove(ctx, from, to)
+		default:
+			entrySkipped = []string{to}
+		}
+		copied = copied || entryCopied
+		skipped = append(skipped, entrySkipped...)
+		if err != nil {
+			return copied, skipped, err
+		}
+	}
+
+	if len(skipped) == 0 {
+		err = t.v.Remove(src)
+	}
+	return copied, skipped, err
+}
+
+// within reports whether path lies strictly inside directory dir
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
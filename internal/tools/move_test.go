@@ -0,0 +1,49 @@
+//go:build unix
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestCopyReplaceFails checks that a copy failing part way leaves the
+// destination it was to replace as it was
+func TestCopyReplaceFails(t *testing.T) {
+	r, dir := newTestTools(t, Options{}, map[string]string{
+		"src/a.txt": "new",
+		"dst/a.txt": "kept",
+		"dst/b.txt": "kept",
+	})
+	// A named pipe cannot be copied, which fails the copy after a.txt
+	if err := syscall.Mkfifo(filepath.Join(dir, "src", "pipe"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Call(context.Background(), "copy_file", map[string]interface{}{
+		"source": "src", "destination": "dst", "overwrite": "replace",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Fatalf("copy succeeded: %s", resultText(result))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d entries in the directory, want src and dst only", len(entries))
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		data, err := os.ReadFile(filepath.Join(dir, "dst", name))
+		if err != nil || string(data) != "kept" {
+			t.Errorf("dst/%s: got %q, %v, want kept", name, data, err)
+		}
+	}
+}
@@ -0,0 +1,639 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// maxFuzz is the number of context lines that may be ignored at each end of
+// a hunk whose context does not match exactly, as with patch --fuzz
+const maxFuzz = 2
+
+// filePatch is the part of a patch changing one file. oldPath is empty for
+// created files and newPath for deleted ones.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []hunk
+
+	// names is set once the ---/+++ lines were read
+	names bool
+}
+
+func (p *filePatch) created() bool { return p.oldPath == "" }
+func (p *filePatch) deleted() bool { return p.newPath == "" }
+func (p *filePatch) renamed() bool { return !p.created() && !p.deleted() && p.oldPath != p.newPath }
+
+// path is the name reports use for the file
+func (p *filePatch) path() string {
+	if p.deleted() {
+		return p.oldPath
+	}
+	return p.newPath
+}
+
+// hunk is one @@ section of a file patch
+type hunk struct {
+	header   string
+	oldStart int
+	oldCount int
+	ops      []diffOp
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch parses a unified diff of one or more files, as produced by diff
+// -u or git diff, including git's headers for created, deleted and renamed
+// files. Lines outside of file patches, such as commit messages, are ignored.
+func parsePatch(text string) ([]*filePatch, error) {
+	lines := splitLines(text)
+	var files []*filePatch
+	var current *filePatch
+
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], "\r\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = &filePatch{}
+			files = append(files, current)
+			if oldPath, newPath, ok := parseGitHeader(line); ok {
+				current.oldPath, current.newPath = oldPath, newPath
+			}
+			i++
+
+		case current != nil && !current.names && strings.HasPrefix(line, "rename from "):
+			current.oldPath = parseName(strings.TrimPrefix(line, "rename from "))
+			i++
+
+		case current != nil && !current.names && strings.HasPrefix(line, "rename to "):
+			current.newPath = parseName(strings.TrimPrefix(line, "rename to "))
+			i++
+
+		case current != nil && !current.names && strings.HasPrefix(line, "new file mode "):
+			current.oldPath = ""
+			i++
+
+		case current != nil && !current.names && strings.HasPrefix(line, "deleted file mode "):
+			current.newPath = ""
+			i++
+
+		case strings.HasPrefix(line, "GIT binary patch"), strings.HasPrefix(line, "Binary files "):
+			return nil, fmt.Errorf("line %d: binary patches are not supported", i+1)
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if current == nil || current.names || len(current.hunks) > 0 {
+				current = &filePatch{}
+				files = append(files, current)
+			}
+			oldPath := parseName(strings.TrimPrefix(line, "--- "))
+			newPath := parseName(strings.TrimPrefix(strings.TrimRight(lines[i+1], "\r\n"), "+++ "))
+			current.oldPath, current.newPath = stripPrefixes(oldPath, newPath)
+			current.names = true
+			i += 2
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without a file header", i+1)
+			}
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.hunks = append(current.hunks, h)
+			i = next
+
+		default:
+			i++
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no file changes found; expected a unified diff with ---/+++ headers and @@ hunks")
+	}
+	for _, file := range files {
+		if file.oldPath == "" && file.newPath == "" {
+			return nil, errors.New("a file patch names no file")
+		}
+	}
+	return files, nil
+}
+
+// parseGitHeader reads the paths of a "diff --git a/old b/new" line
+func parseGitHeader(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if strings.HasPrefix(rest, `"`) {
+		// Quoted names are taken from the ---/+++ or rename lines instead
+		return "", "", false
+	}
+	i := strings.Index(rest, " b/")
+	if !strings.HasPrefix(rest, "a/") || i < 0 {
+		return "", "", false
+	}
+	return rest[2:i], rest[i+3:], true
+}
+
+// parseName reads a file name from a ---/+++ or git header line, dropping
+// the timestamp diff appends after a tab. /dev/null yields "".
+func parseName(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		s = unquoted
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// stripPrefixes drops the a/ and b/ prefixes git puts on file names
+func stripPrefixes(oldPath, newPath string) (string, string) {
+	if (oldPath == "" || strings.HasPrefix(oldPath, "a/")) && (newPath == "" || strings.HasPrefix(newPath, "b/")) {
+		return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+	}
+	return oldPath, newPath
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns it along
+// with the index of the line after it
+func parseHunk(lines []string, start int) (hunk, int, error) {
+	header := strings.TrimRight(lines[start], "\r\n")
+	m := hunkHeader.FindStringSubmatch(header)
+	if m == nil {
+		return hunk{}, 0, fmt.Errorf("line %d: invalid hunk header %q", start+1, header)
+	}
+
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	oldStart, _ := strconv.Atoi(m[1])
+	h := hunk{header: m[0], oldStart: oldStart, oldCount: count(m[2])}
+	oldLeft, newLeft := h.oldCount, count(m[4])
+
+	i := start + 1
+	for ; i < len(lines) && (oldLeft > 0 || newLeft > 0); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, `\`):
+			noNewlineAtEnd(&h)
+			continue
+		case line == "\n" || line == "\r\n":
+			// Editors often strip the space of empty context lines
+			h.ops = append(h.ops, diffOp{' ', line})
+			oldLeft--
+			newLeft--
+		case line[0] == ' ':
+			h.ops = append(h.ops, diffOp{' ', line[1:]})
+			oldLeft--
+			newLeft--
+		case line[0] == '-':
+			h.ops = append(h.ops, diffOp{'-', line[1:]})
+			oldLeft--
+		case line[0] == '+':
+			h.ops = append(h.ops, diffOp{'+', line[1:]})
+			newLeft--
+		default:
+			return hunk{}, 0, fmt.Errorf("line %d: hunk %s ends early", i+1, h.header)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return hunk{}, 0, fmt.Errorf("line %d: hunk %s has more lines than its header says", i+1, h.header)
+		}
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return hunk{}, 0, fmt.Errorf("hunk %s ends early at the end of the patch", h.header)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+		noNewlineAtEnd(&h)
+		i++
+	}
+	return h, i, nil
+}
+
+// noNewlineAtEnd applies a "\ No newline at end of file" marker to the last line of h
+func noNewlineAtEnd(h *hunk) {
+	if len(h.ops) > 0 {
+		last := &h.ops[len(h.ops)-1]
+		last.line = strings.TrimSuffix(strings.TrimSuffix(last.line, "\n"), "\r")
+	}
+}
+
+// hunkOutcome records where a hunk applied, or why it did not
+type hunkOutcome struct {
+	offset   int
+	fuzz     int
+	rejected string
+}
+
+// applyHunks applies hunks to content. A hunk whose context is not at the
+// line its header names is looked for nearby, and failing that, up to
+// maxFuzz context lines at either end are ignored. Hunks that still do not
+// match are rejected with an explanation.
+func applyHunks(content string, hunks []hunk) (string, []hunkOutcome) {
+	lines := splitLines(content)
+	outcomes := make([]hunkOutcome, len(hunks))
+
+	var out []string
+	pos := 0   // first line of content not yet copied to out
+	delta := 0 // how far the previous hunk was from where its header said
+	for n, h := range hunks {
+		ops := h.ops
+		var at, lead, fuzz int
+		for fuzz = 0; fuzz <= maxFuzz; fuzz++ {
+			var trail int
+			ops, lead, trail = trimContext(h.ops, fuzz)
+			if fuzz > 0 && lead+trail == 0 {
+				at = -1
+				break
+			}
+
+			// The header names the line before the hunk when it removes nothing
+			want := h.oldStart - 1 + lead + delta
+			if h.oldCount == 0 {
+				want = h.oldStart + delta
+			}
+			at = findLines(lines, oldLines(ops), want, pos)
+			if at >= 0 {
+				break
+			}
+		}
+		if at < 0 {
+			outcomes[n].rejected = describeMismatch(lines, h, h.oldStart-1+delta)
+			continue
+		}
+
+		stated := h.oldStart - 1 + lead
+		if h.oldCount == 0 {
+			stated = h.oldStart
+		}
+		delta = at - stated
+		outcomes[n] = hunkOutcome{offset: delta, fuzz: fuzz}
+
+		out = append(out, lines[pos:at]...)
+		for _, op := range ops {
+			switch op.kind {
+			case ' ':
+				// Keep the file's own version of context lines, which may
+				// differ in line endings
+				out = append(out, lines[at])
+				at++
+			case '-':
+				at++
+			case '+':
+				out = append(out, op.line)
+			}
+		}
+		pos = at
+	}
+	out = append(out, lines[pos:]...)
+
+	// Lines added after a last line without a line break need one
+	for i := 0; i < len(out)-1; i++ {
+		if !strings.HasSuffix(out[i], "\n") {
+			out[i] += "\n"
+		}
+	}
+	return strings.Join(out, ""), outcomes
+}
+
+// trimContext drops up to fuzz context lines from each end of ops and
+// returns the rest along with the numbers of lines dropped
+func trimContext(ops []diffOp, fuzz int) ([]diffOp, int, int) {
+	lead := 0
+	for lead < fuzz && lead < len(ops) && ops[lead].kind == ' ' {
+		lead++
+	}
+	ops = ops[lead:]
+	trail := 0
+	for trail < fuzz && trail < len(ops) && ops[len(ops)-1-trail].kind == ' ' {
+		trail++
+	}
+	return ops[:len(ops)-trail], lead, trail
+}
+
+// oldLines returns the lines ops expect to find: context and removed lines
+func oldLines(ops []diffOp) []string {
+	var lines []string
+	for _, op := range ops {
+		if op.kind != '+' {
+			lines = append(lines, op.line)
+		}
+	}
+	return lines
+}
+
+// findLines returns the index at or after min where lines contain want,
+// choosing the one closest to near, or -1 when there is none. Line endings
+// are ignored.
+func findLines(lines, want []string, near, min int) int {
+	last := len(lines) - len(want)
+	if last < min {
+		return -1
+	}
+	near = max(min, near)
+	for distance := 0; near-distance >= min || near+distance <= last; distance++ {
+		if below := near - distance; below >= min && below <= last && linesEqual(lines[below:], want) {
+			return below
+		}
+		if above := near + distance; distance > 0 && above <= last && linesEqual(lines[above:], want) {
+			return above
+		}
+	}
+	return -1
+}
+
+func linesEqual(a, b []string) bool {
+	for i := range b {
+		if strings.TrimRight(a[i], "\r\n") != strings.TrimRight(b[i], "\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// describeMismatch explains a rejected hunk by showing what it expected
+// next to what the file has where the hunk should apply
+func describeMismatch(lines []string, h hunk, at int) string {
+	want := oldLines(h.ops)
+	at = max(0, min(at, len(lines)))
+	have := lines[at:min(at+len(want), len(lines))]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "the hunk expects these lines at line %d:\n", at+1)
+	for _, line := range want {
+		b.WriteString("  " + strings.TrimRight(line, "\r\n") + "\n")
+	}
+	if len(have) == 0 {
+		b.WriteString("but the file ends there")
+		return b.String()
+	}
+	b.WriteString("but the file has:\n")
+	for _, line := range have {
+		b.WriteString("  " + strings.TrimRight(line, "\r\n") + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type applyPatchArgs struct {
+	Patch  string `json:"patch"`
+	DryRun bool   `json:"dryRun"`
+}
+
+// fileChange is the planned change to one file: its new content, or nil to
+// remove it, along with what is needed to undo it. For a symlink, content
+// and before hold the link's target, as git records it.
+type fileChange struct {
+	path    string
+	content []byte
+	existed bool
+	before  []byte
+	mode    fs.FileMode
+	symlink bool
+}
+
+func (t *toolset) applyPatch() Tool {
+	const name = "apply_patch"
+	return Tool{
+		Name: name,
+		Description: "Apply a unified diff, as produced by diff -u or git diff, to one or more files. Handles created, " +
+			"deleted and renamed files, and hunks whose lines moved or whose context changed slightly. Either every " +
+			"file is changed or, when any hunk does not apply, none is and the failing hunks are explained.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"patch":  stringProperty("The unified diff; paths are resolved like other paths, after dropping git's a/ and b/ prefixes"),
+				"dryRun": booleanProperty("Check that the patch applies without writing any file"),
+			},
+			Required: []string{"patch"},
+		},
+		Annotations: Annotations{Title: "Apply Patch", Destructive: true},
+		Handler: Typed(func(ctx context.Context, args applyPatchArgs) (*Result, error) {
+			files, err := parsePatch(args.Patch)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error parsing patch: %v", err)), nil
+			}
+
+			changes, summary, rejects, err := t.planPatch(ctx, name, files)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Patch not applied, no files were changed: %v", err)), nil
+			}
+			if len(rejects) > 0 {
+				return ErrorResult(fmt.Sprintf("Patch not applied, no files were changed: %d of %d hunks failed.\n\n%s",
+					len(rejects), countHunks(files), strings.Join(rejects, "\n\n"))), nil
+			}
+
+			if args.DryRun {
+				return TextResult("Dry run, nothing was written. The patch applies cleanly:\n" + summary), nil
+			}
+			if err := t.commitChanges(ctx, changes); err != nil {
+				return ErrorResult(fmt.Sprintf("Patch not applied: %v", err)), nil
+			}
+			return TextResult("Applied patch:\n" + summary), nil
+		}),
+	}
+}
+
+func countHunks(files []*filePatch) int {
+	n := 0
+	for _, file := range files {
+		n += len(file.hunks)
+	}
+	return n
+}
+
+// planPatch validates every path of a patch and applies its hunks in
+// memory. It returns the changes to make, a summary of them and the
+// explanations of rejected hunks; an error means the patch cannot apply at all.
+func (t *toolset) planPatch(ctx context.Context, name string, files []*filePatch) ([]fileChange, string, []string, error) {
+	var changes []fileChange
+	var summary strings.Builder
+	var rejects []string
+	touched := make(map[string]bool)
+
+	// validate resolves a path of the patch for the given use and checks
+	// that the patch touches it only once
+	validate := func(path string, use pathUse) (string, error) {
+		validPath, err := t.resolvePath(ctx, name, path, use)
+		if err != nil {
+			return "", err
+		}
+		if touched[validPath] {
+			return "", fmt.Errorf("the patch changes %s more than once", path)
+		}
+		touched[validPath] = true
+		return validPath, nil
+	}
+
+	for _, file := range files {
+		var oldPath, newPath string
+		var before []byte
+		var mode fs.FileMode = 0644
+		var symlink bool
+		var err error
+
+		if !file.created() {
+			// A deleted or renamed symlink is removed itself, so its
+			// target is left alone
+			use := writePath
+			if file.deleted() || file.renamed() {
+				use = removePath
+			}
+			if oldPath, err = validate(file.oldPath, use); err != nil {
+				return nil, "", nil, err
+			}
+			info, err := t.v.Lstat(oldPath)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("error reading %s: %v", file.oldPath, err)
+			}
+			switch {
+			case info.Mode()&fs.ModeSymlink != 0:
+				symlink = true
+				target, err := t.v.Readlink(oldPath)
+				if err != nil {
+					return nil, "", nil, fmt.Errorf("error reading %s: %v", file.oldPath, err)
+				}
+				before = []byte(target)
+			case info.Mode().IsRegular():
+				mode = info.Mode().Perm()
+				if before, err = t.v.ReadFile(ctx, oldPath); err != nil {
+					return nil, "", nil, fmt.Errorf("error reading %s: %v", file.oldPath, err)
+				}
+				if !filesystem.IsText(before) {
+					return nil, "", nil, fmt.Errorf("%s is not a text file", file.oldPath)
+				}
+			default:
+				return nil, "", nil, fmt.Errorf("%s is not a regular file", file.oldPath)
+			}
+		}
+		if !file.deleted() {
+			newPath = oldPath
+			if file.created() || file.renamed() {
+				if newPath, err = validate(file.newPath, writePath); err != nil {
+					return nil, "", nil, err
+				}
+				if _, err := t.v.Stat(newPath); err == nil {
+					return nil, "", nil, fmt.Errorf("%s already exists", file.newPath)
+				}
+			}
+		}
+
+		after, outcomes := applyHunks(string(before), file.hunks)
+		rejected := false
+		for i, outcome := range outcomes {
+			if outcome.rejected != "" {
+				rejected = true
+				rejects = append(rejects, fmt.Sprintf("%s: hunk %d (%s): %s", file.path(), i+1, file.hunks[i].header, outcome.rejected))
+			}
+		}
+		if file.deleted() && after != "" && !rejected {
+			rejected = true
+			rejects = append(rejects, fmt.Sprintf("%s: the file has content the patch does not delete", file.path()))
+		}
+		if rejected {
+			continue
+		}
+		if symlink && file.renamed() && after != string(before) {
+			return nil, "", nil, fmt.Errorf("%s is a symlink, which the patch can rename or delete but not point elsewhere", file.oldPath)
+		}
+
+		switch {
+		case file.created():
+			fmt.Fprintf(&summary, "  created %s\n", file.newPath)
+		case file.deleted():
+			fmt.Fprintf(&summary, "  deleted %s\n", file.oldPath)
+		case file.renamed():
+			fmt.Fprintf(&summary, "  renamed %s to %s\n", file.oldPath, file.newPath)
+		default:
+			fmt.Fprintf(&summary, "  patched %s\n", file.newPath)
+		}
+		for i, outcome := range outcomes {
+			if outcome.offset != 0 || outcome.fuzz != 0 {
+				fmt.Fprintf(&summary, "    hunk %d applied %s\n", i+1, describeOutcome(outcome))
+			}
+		}
+
+		if !file.deleted() {
+			changes = append(changes, fileChange{path: newPath, content: []byte(after), existed: !file.created() && !file.renamed(), before: before, mode: mode, symlink: symlink})
+		}
+		if file.deleted() || file.renamed() {
+			changes = append(changes, fileChange{path: oldPath, existed: true, before: before, mode: mode, symlink: symlink})
+		}
+	}
+	return changes, summary.String(), rejects, nil
+}
+
+func describeOutcome(outcome hunkOutcome) string {
+	var parts []string
+	if outcome.offset != 0 {
+		unit := "lines"
+		if outcome.offset == 1 || outcome.offset == -1 {
+			unit = "line"
+		}
+		parts = append(parts, fmt.Sprintf("with offset %+d %s", outcome.offset, unit))
+	}
+	if outcome.fuzz != 0 {
+		parts = append(parts, fmt.Sprintf("with fuzz %d", outcome.fuzz))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// commitChanges makes the planned changes. When one fails, those already
+// made are undone so that the files are left as they were.
+func (t *toolset) commitChanges(ctx context.Context, changes []fileChange) error {
+	for i, change := range changes {
+		var err error
+		switch {
+		case change.content == nil:
+			err = t.v.RemoveAll(ctx, change.path)
+		case change.symlink:
+			err = t.v.MkdirAll(filepath.Dir(change.path), 0755)
+			if err == nil {
+				err = t.v.Symlink(string(change.content), change.path)
+			}
+		case change.existed:
+			err = t.v.ReplaceFile(change.path, change.content)
+		default:
+			err = t.v.MkdirAll(filepath.Dir(change.path), 0755)
+			if err == nil {
+				err = t.v.WriteFile(change.path, change.content, change.mode)
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			t.undoChange(changes[j])
+		}
+		return fmt.Errorf("error writing %s, no files were changed: %v", change.path, err)
+	}
+	return nil
+}
+
+// undoChange restores a file changed by commitChanges
+func (t *toolset) undoChange(change fileChange) {
+	switch {
+	case change.content == nil && change.symlink:
+		t.v.Symlink(string(change.before), change.path)
+	case change.content == nil:
+		t.v.WriteFile(change.path, change.before, change.mode)
+	case change.existed:
+		t.v.ReplaceFile(change.path, change.before)
+	default:
+		// The change may have failed because ctx was cancelled
+		t.v.RemoveAll(context.Background(), change.path)
+	}
+}
@@ -0,0 +1,352 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+type readFileArgs struct {
+	Path        string `json:"path"`
+	Offset      int64  `json:"offset"`
+	Limit       int64  `json:"limit"`
+	ByteOffset  *int64 `json:"byteOffset"`
+	ByteLength  *int64 `json:"byteLength"`
+	LineNumbers bool   `json:"lineNumbers"`
+	Encoding    string `json:"encoding"`
+}
+
+// Encodings of read_file content
+const (
+	encodingAuto   = "auto"
+	encodingText   = "text"
+	encodingBase64 = "base64"
+)
+
+// validate rejects arguments that are out of range or mix line and byte ranges
+func (args readFileArgs) validate() error {
+	switch {
+	case args.Offset < 0:
+		return &ArgumentError{Message: "Invalid parameter offset: must be at least 1"}
+	case args.Limit < 0:
+		return &ArgumentError{Message: "Invalid parameter limit: must be at least 1"}
+	case args.ByteOffset != nil && *args.ByteOffset < 0:
+		return &ArgumentError{Message: "Invalid parameter byteOffset: must not be negative"}
+	case args.ByteLength != nil && *args.ByteLength < 0:
+		return &ArgumentError{Message: "Invalid parameter byteLength: must not be negative"}
+	case args.byteRange() && args.lineRange():
+		return &ArgumentError{Message: "Invalid parameters: offset, limit and lineNumbers cannot be combined with byteOffset and byteLength"}
+	case args.Encoding != "" && args.Encoding != encodingAuto && args.Encoding != encodingText && args.Encoding != encodingBase64:
+		return &ArgumentError{Message: "Invalid parameter encoding: must be auto, text or base64"}
+	case args.Encoding == encodingBase64 && args.lineRange():
+		return &ArgumentError{Message: "Invalid parameters: offset, limit and lineNumbers read text and cannot be combined with encoding base64"}
+	}
+	return nil
+}
+
+func (args readFileArgs) byteRange() bool {
+	return args.ByteOffset != nil || args.ByteLength != nil
+}
+
+func (args readFileArgs) lineRange() bool {
+	return args.Offset > 0 || args.Limit > 0 || args.LineNumbers
+}
+
+func (t *toolset) readFile() Tool {
+	const name = "read_file"
+	return Tool{
+		Name: name,
+		Description: fmt.Sprintf("Read the contents of a file from the filesystem. Content beyond %d bytes is cut short "+
+			"with a note on where to continue; page through large files with offset and limit (lines) or "+
+			"byteOffset and byteLength, and set lineNumbers to prefix each line with its number. Binary files are "+
+			"returned as image content or as a base64 blob.", t.opts.MaxReadBytes),
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path":        stringProperty("Path to the file to read"),
+				"offset":      integerProperty("Line number to start reading at, counting from 1", 1),
+				"limit":       integerProperty("Maximum number of lines to read", 1),
+				"byteOffset":  integerProperty("Byte offset to start reading at, counting from 0", 0),
+				"byteLength":  integerProperty("Maximum number of bytes to read", 0),
+				"lineNumbers": booleanProperty("Prefix each line with its line number"),
+				"encoding": map[string]interface{}{
+					"type":        "string",
+					"description": "How to return the content: auto (default) returns text files as text, images as image content and other files as a base64 blob; text and base64 force one or the other",
+					"enum":        []string{encodingAuto, encodingText, encodingBase64},
+				},
+			},
+			Required: []string{"path"},
+		},
+		Annotations: Annotations{Title: "Read File", ReadOnly: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args readFileArgs) (*Result, error) {
+			if err := args.validate(); err != nil {
+				return nil, err
+			}
+
+			return t.read(ctx, name, args), nil
+		}),
+	}
+}
+
+// read validates and authorizes the path of args under the given tool name
+// and reads the file as read_file does
+func (t *toolset) read(ctx context.Context, name string, args readFileArgs) *Result {
+	validPath, err := t.resolvePath(ctx, name, args.Path, readPath)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	if args.lineRange() {
+		return t.readLines(ctx, validPath, args)
+	}
+	return t.readBytes(ctx, validPath, args)
+}
+
+// readConcurrency is the number of files read_multiple_files reads at once
+const readConcurrency = 8
+
+// maxReadPaths is the most paths one read_multiple_files call accepts
+const maxReadPaths = 100
+
+type readMultipleFilesArgs struct {
+	Paths []string `json:"paths"`
+}
+
+func (t *toolset) readMultipleFiles() Tool {
+	const name = "read_multiple_files"
+	return Tool{
+		Name: name,
+		Description: fmt.Sprintf("Read up to %d files at once, which is faster than reading them one by one. Each "+
+			"file is read like read_file without ranges and labelled with its path; a file that cannot be read is "+
+			"reported without failing the others. The files together return at most %d bytes; the files that do "+
+			"not fit are listed at the end to be read in another call.", maxReadPaths, t.opts.MaxReadBytes),
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"paths": map[string]interface{}{
+					"type":        "array",
+					"description": "Paths of the files to read",
+					"items":       map[string]interface{}{"type": "string"},
+					"minItems":    1,
+					"maxItems":    maxReadPaths,
+				},
+			},
+			Required: []string{"paths"},
+		},
+		Annotations: Annotations{Title: "Read Multiple Files", ReadOnly: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args readMultipleFilesArgs) (*Result, error) {
+			if len(args.Paths) == 0 {
+				return nil, &ArgumentError{Message: "Invalid parameter paths: must list at least one path"}
+			}
+			if len(args.Paths) > maxReadPaths {
+				return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameter paths: must list at most %d paths", maxReadPaths)}
+			}
+
+			// Concurrent reads would report progress of different files
+			// interleaved, which clients cannot make sense of
+			readCtx := filesystem.WithProgress(ctx, nil)
+
+			results := make([]*Result, len(args.Paths))
+			workers := make(chan struct{}, readConcurrency)
+			var wg sync.WaitGroup
+			for i, path := range args.Paths {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					workers <- struct{}{}
+					defer func() { <-workers }()
+					results[i] = t.read(readCtx, name, readFileArgs{Path: path})
+				}()
+			}
+			wg.Wait()
+
+			combined := &Result{}
+			var size int64
+			for i, result := range results {
+				var content []Content
+				label := args.Paths[i] + ":"
+				if result.IsError {
+					label += " " + result.Content[0].Text
+					content = []Content{{Type: "text", Text: label}}
+				} else if first := result.Content[0]; first.Type == "text" {
+					// Text is labelled in place; other content follows its label
+					first.Text = label + "\n" + first.Text
+					content = append([]Content{first}, result.Content[1:]...)
+				} else {
+					content = append([]Content{{Type: "text", Text: label}}, result.Content...)
+				}
+
+				// Always return the first file, which read cut short already
+				size += contentSize(content)
+				if i > 0 && size > t.opts.MaxReadBytes {
+					combined.Content = append(combined.Content, Content{
+						Type: "text",
+						Text: fmt.Sprintf("[Not returned, as the files above reach the limit of %d bytes per call: %s. Read them in another call.]",
+							t.opts.MaxReadBytes, strings.Join(args.Paths[i:], ", ")),
+					})
+					break
+				}
+				combined.Content = append(combined.Content, content...)
+			}
+			return combined, nil
+		}),
+	}
+}
+
+// contentSize returns the bytes of text and encoded data in content
+func contentSize(content []Content) int64 {
+	var size int
+	for _, c := range content {
+		size += len(c.Text) + len(c.Data)
+		if c.Resource != nil {
+			size += len(c.Resource.Blob)
+		}
+	}
+	return int64(size)
+}
+
+// readBytes reads a byte range of a file, the whole file by default, and
+// cuts it short at MaxReadBytes. Text is returned as text; binary content
+// as an image when it is a whole image and as a base64 blob otherwise.
+func (t *toolset) readBytes(ctx context.Context, path string, args readFileArgs) *Result {
+	var offset int64
+	if args.ByteOffset != nil {
+		offset = *args.ByteOffset
+	}
+	length := t.opts.MaxReadBytes
+	capped := true
+	if args.ByteLength != nil && *args.ByteLength <= length {
+		length = *args.ByteLength
+		capped = false
+	}
+
+	content, size, err := t.v.ReadFileRange(ctx, path, offset, length)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error reading file: %v", err))
+	}
+	if size >= 0 && offset > size {
+		return ErrorResult(fmt.Sprintf("byteOffset %d is past the end of the file, which has %d bytes", offset, size))
+	}
+
+	end := offset + int64(len(content))
+	truncated := capped && end < size
+
+	text := content
+	if truncated {
+		// Do not leave half a character at the cut, unless that is all there is
+		if trimmed := trimPartialRune(content); len(trimmed) > 0 {
+			text = trimmed
+		}
+	}
+
+	binary := args.Encoding == encodingBase64 || (args.Encoding != encodingText && !filesystem.IsText(text))
+	if !binary {
+		end = offset + int64(len(text))
+		result := TextResult(strings.ToValidUTF8(string(text), "\uFFFD"))
+		if truncated {
+			result.Content[0].Text += fmt.Sprintf("\n\n[Truncated: showing bytes %d-%d of %d. Read more with byteOffset=%d, or page by lines with offset and limit.]",
+				offset, end-1, size, end)
+		}
+		return result
+	}
+
+	mimeType := filesystem.MIMEType(path, content)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	// Only a whole image can be shown as one
+	whole := offset == 0 && end == size
+	if args.Encoding != encodingBase64 && whole && strings.HasPrefix(mimeType, "image/") {
+		return &Result{Content: []Content{ImageContent(content, mimeType)}}
+	}
+
+	result := &Result{Content: []Content{BlobContent(filesystem.FileURI(path), mimeType, content)}}
+	if truncated {
+		result.Content = append(result.Content, Content{
+			Type: "text",
+			Text: fmt.Sprintf("[Truncated: showing bytes %d-%d of %d. Read more with byteOffset=%d.]", offset, end-1, size, end),
+		})
+	}
+	return result
+}
+
+// readLines reads a range of lines of a file, optionally numbered, and stops
+// before the output would exceed MaxReadBytes
+func (t *toolset) readLines(ctx context.Context, path string, args readFileArgs) *Result {
+	start := max(args.Offset, 1)
+	maxBytes := int(t.opts.MaxReadBytes)
+
+	var output strings.Builder
+	var lineNo, last int64
+	more, binary := false, false
+	err := t.v.ScanLines(ctx, path, maxBytes, func(line []byte, cut bool) bool {
+		lineNo++
+		if lineNo < start {
+			return true
+		}
+		if args.Limit > 0 && lineNo >= start+args.Limit {
+			more = true
+			return false
+		}
+
+		text := line
+		if cut {
+			text = trimPartialRune(line)
+		}
+		if args.Encoding != encodingText && !filesystem.IsText(text) {
+			binary = true
+			return false
+		}
+
+		var entry strings.Builder
+		if args.LineNumbers {
+			fmt.Fprintf(&entry, "%6d\t", lineNo)
+		}
+		entry.WriteString(strings.ToValidUTF8(string(text), "\uFFFD"))
+		if cut {
+			fmt.Fprintf(&entry, " [line truncated at %d bytes]", maxBytes)
+		}
+		entry.WriteByte('\n')
+
+		// Always show at least one line, however long
+		if last > 0 && output.Len()+entry.Len() > maxBytes {
+			more = true
+			return false
+		}
+		output.WriteString(entry.String())
+		last = lineNo
+		return true
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error reading file: %v", err))
+	}
+
+	if binary {
+		return ErrorResult(fmt.Sprintf("%s is not a text file: read it without offset, limit and lineNumbers, or set encoding to text", path))
+	}
+	if last == 0 && start > 1 {
+		return ErrorResult(fmt.Sprintf("offset %d is past the end of the file, which has %d lines", start, lineNo))
+	}
+	if more {
+		fmt.Fprintf(&output, "\n[Showing lines %d-%d; the file continues. Read more with offset=%d.]", start, last, last+1)
+	}
+	return TextResult(output.String())
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of data
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// newTestTools returns the tools on a temporary directory holding files,
+// and the directory
+func newTestTools(t *testing.T, opts Options, files map[string]string) (*Registry, string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := filesystem.NewValidator(filesystem.RootSpec{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	return NewFilesystem(v, opts), dir
+}
+
+// resultText joins the text items of a result
+func resultText(result *Result) string {
+	var texts []string
+	for _, content := range result.Content {
+		texts = append(texts, content.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func TestReadMultipleFilesLimit(t *testing.T) {
+	r, _ := newTestTools(t, Options{MaxReadBytes: 32}, map[string]string{
+		"a.txt":   strings.Repeat("a", 20),
+		"b.txt":   strings.Repeat("b", 20),
+		"c.txt":   "c",
+		"big.txt": strings.Repeat("x", 40),
+	})
+
+	result, err := r.Call(context.Background(), "read_multiple_files", map[string]interface{}{
+		"paths": []interface{}{"a.txt", "b.txt", "c.txt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.txt:\n" + strings.Repeat("a", 20) + "\n" +
+		"[Not returned, as the files above reach the limit of 32 bytes per call: b.txt, c.txt. Read them in another call.]"
+	if got := resultText(result); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The first file is returned even when it reaches the limit on its own
+	result, err = r.Call(context.Background(), "read_multiple_files", map[string]interface{}{
+		"paths": []interface{}{"big.txt", "c.txt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "big.txt:\n" + strings.Repeat("x", 32) +
+		"\n\n[Truncated: showing bytes 0-31 of 40. Read more with byteOffset=32, or page by lines with offset and limit.]\n" +
+		"[Not returned, as the files above reach the limit of 32 bytes per call: c.txt. Read them in another call.]"
+	if got := resultText(result); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadMultipleFilesPaths(t *testing.T) {
+	r, _ := newTestTools(t, Options{}, nil)
+
+	for _, n := range []int{0, maxReadPaths + 1} {
+		paths := make([]interface{}, n)
+		for i := range paths {
+			paths[i] = fmt.Sprintf("%d.txt", i)
+		}
+		_, err := r.Call(context.Background(), "read_multiple_files", map[string]interface{}{"paths": paths})
+		var argErr *ArgumentError
+		if !errors.As(err, &argErr) {
+			t.Errorf("%d paths: got error %v, want an argument error", n, err)
+		}
+	}
+}
+
+func TestReadFileByteOffset(t *testing.T) {
+	r, _ := newTestTools(t, Options{}, map[string]string{"empty.txt": "", "a.txt": "abc"})
+
+	tests := []struct {
+		path   string
+		offset int
+		want   string
+		isErr  bool
+	}{
+		{"a.txt", 3, "", false},
+		{"a.txt", 4, "byteOffset 4 is past the end of the file, which has 3 bytes", true},
+		{"empty.txt", 0, "", false},
+		{"empty.txt", 1, "byteOffset 1 is past the end of the file, which has 0 bytes", true},
+	}
+	for _, tt := range tests {
+		result, err := r.Call(context.Background(), "read_file", map[string]interface{}{"path": tt.path, "byteOffset": tt.offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resultText(result); got != tt.want || result.IsError != tt.isErr {
+			t.Errorf("%s at %d: got %q (error %v), want %q (error %v)", tt.path, tt.offset, got, result.IsError, tt.want, tt.isErr)
+		}
+	}
+}
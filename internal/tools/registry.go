@@ -0,0 +1,194 @@
+// Package tools implements the filesystem tools once for both servers. Each
+// server translates the tool descriptions and results to and from its own
+// protocol types.
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownTool is returned when calling a tool that is not registered
+var ErrUnknownTool = errors.New("unknown tool")
+
+// ArgumentError reports tool arguments that are missing or of the wrong
+// type. Servers answer it as invalid params rather than as a tool failure.
+type ArgumentError struct {
+	Message string
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Message
+}
+
+// Schema is a JSON Schema object describing tool input or output
+type Schema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Required   []string               `json:"required,omitempty"`
+}
+
+// Annotations are hints about a tool's behavior for clients
+type Annotations struct {
+	Title       string
+	ReadOnly    bool
+	Destructive bool
+	Idempotent  bool
+	OpenWorld   bool
+}
+
+// Content is one item of a tool result: Text for "text" items, base64 Data
+// and MIMEType for "image" items, and Resource for "resource" items
+type Content struct {
+	Type     string
+	Text     string
+	Data     string
+	MIMEType string
+	Resource *EmbeddedResource
+}
+
+// EmbeddedResource is a file embedded in a tool result as a base64 blob
+type EmbeddedResource struct {
+	URI      string
+	MIMEType string
+	Blob     string
+}
+
+// ImageContent returns an image item holding data
+func ImageContent(data []byte, mimeType string) Content {
+	return Content{Type: "image", Data: base64.StdEncoding.EncodeToString(data), MIMEType: mimeType}
+}
+
+// BlobContent returns a resource item embedding data as the content of uri
+func BlobContent(uri, mimeType string, data []byte) Content {
+	return Content{
+		Type: "resource",
+		Resource: &EmbeddedResource{
+			URI:      uri,
+			MIMEType: mimeType,
+			Blob:     base64.StdEncoding.EncodeToString(data),
+		},
+	}
+}
+
+// Result is the outcome of a tool call. Structured, when set, conforms to
+// the tool's OutputSchema.
+type Result struct {
+	Content    []Content
+	Structured interface{}
+	IsError    bool
+}
+
+// TextResult returns a result holding a single text item
+func TextResult(text string) *Result {
+	return &Result{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// ErrorResult returns a failed result whose text explains the failure to the model
+func ErrorResult(text string) *Result {
+	result := TextResult(text)
+	result.IsError = true
+	return result
+}
+
+// Handler implements a tool. An error other than *ArgumentError is reported
+// to the client as a failed result.
+type Handler func(ctx context.Context, arguments map[string]interface{}) (*Result, error)
+
+// Tool describes a tool and implements it
+type Tool struct {
+	Name         string
+	Description  string
+	InputSchema  Schema
+	OutputSchema *Schema
+	Annotations  Annotations
+	Handler      Handler
+}
+
+// Typed adapts a handler taking its arguments as a struct, decoded from the
+// call's arguments with encoding/json
+func Typed[A any](fn func(ctx context.Context, args A) (*Result, error)) Handler {
+	return func(ctx context.Context, arguments map[string]interface{}) (*Result, error) {
+		var args A
+		data, _ := json.Marshal(arguments)
+		if err := json.Unmarshal(data, &args); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameter %s: expected %s", typeErr.Field, typeErr.Type)}
+			}
+			return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameters: %v", err)}
+		}
+		return fn(ctx, args)
+	}
+}
+
+// Registry holds the tools a server offers, in registration order
+type Registry struct {
+	tools  []Tool
+	byName map[string]int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]int)}
+}
+
+// Register adds a tool; registering two tools with one name is a programming error
+func (r *Registry) Register(tool Tool) {
+	if _, ok := r.byName[tool.Name]; ok {
+		panic(fmt.Sprintf("tools: duplicate tool %s", tool.Name))
+	}
+	r.byName[tool.Name] = len(r.tools)
+	r.tools = append(r.tools, tool)
+}
+
+// Tools returns the registered tools in registration order
+func (r *Registry) Tools() []Tool {
+	return r.tools
+}
+
+// Lookup returns the tool with the given name
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	i, ok := r.byName[name]
+	if !ok {
+		return Tool{}, false
+	}
+	return r.tools[i], true
+}
+
+// Call runs the named tool. It returns ErrUnknownTool or an *ArgumentError
+// when the call itself is invalid; failures of the tool are reported in the
+// returned result instead.
+func (r *Registry) Call(ctx context.Context, name string, arguments map[string]interface{}) (*Result, error) {
+	tool, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, name)
+	}
+
+	var missing []string
+	for _, param := range tool.InputSchema.Required {
+		if _, ok := arguments[param]; !ok {
+			missing = append(missing, param)
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return nil, &ArgumentError{Message: fmt.Sprintf("Missing required parameter: %s", missing[0])}
+	default:
+		return nil, &ArgumentError{Message: fmt.Sprintf("Missing required parameters: %s", strings.Join(missing, ", "))}
+	}
+
+	result, err := tool.Handler(ctx, arguments)
+	if err != nil {
+		var argErr *ArgumentError
+		if errors.As(err, &argErr) {
+			return nil, err
+		}
+		return ErrorResult(err.Error()), nil
+	}
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// defaultMaxResults is the number of matches search_files returns unless a
+// call asks for another
+const defaultMaxResults = 200
+
+type searchFilesArgs struct {
+	Path            string   `json:"path"`
+	Pattern         string   `json:"pattern"`
+	ExcludePatterns []string `json:"excludePatterns"`
+	MaxResults      int      `json:"maxResults"`
+	MaxDepth        int      `json:"maxDepth"`
+	IncludeIgnored  bool     `json:"includeIgnored"`
+}
+
+func (t *toolset) searchFiles() Tool {
+	const name = "search_files"
+	return Tool{
+		Name: name,
+		Description: "Find files and directories whose path below a directory matches a glob pattern, in one call. " +
+			"Patterns use * and ? within a path component and ** for any number of components, so **/*.go finds Go " +
+			"files at any depth. Returns paths relative to the directory; directories end in /.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path":    stringProperty("Directory to search from"),
+				"pattern": stringProperty("Glob pattern the path relative to the directory must match, such as **/*.go or src/*/index.ts"),
+				"excludePatterns": map[string]interface{}{
+					"type":        "array",
+					"description": "Glob patterns of relative paths to leave out; an excluded directory is not searched",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+				"maxResults":     integerProperty(fmt.Sprintf("Maximum number of paths to return (default %d)", defaultMaxResults), 1),
+				"maxDepth":       integerProperty("Maximum number of directory levels to descend, 1 for direct entries only (default unlimited)", 1),
+				"includeIgnored": booleanProperty("Include paths matched by .gitignore and .mcpignore files"),
+			},
+			Required: []string{"path", "pattern"},
+		},
+		Annotations: Annotations{Title: "Search Files", ReadOnly: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args searchFilesArgs) (*Result, error) {
+			if err := filesystem.ValidateGlob(args.Pattern); err != nil {
+				return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameter pattern: %v", err)}
+			}
+			for _, pattern := range args.ExcludePatterns {
+				if err := filesystem.ValidateGlob(pattern); err != nil {
+					return nil, &ArgumentError{Message: fmt.Sprintf("Invalid parameter excludePatterns: %v", err)}
+				}
+			}
+			switch {
+			case args.MaxResults < 0:
+				return nil, &ArgumentError{Message: "Invalid parameter maxResults: must be at least 1"}
+			case args.MaxDepth < 0:
+				return nil, &ArgumentError{Message: "Invalid parameter maxDepth: must be at least 1"}
+			case args.MaxResults == 0:
+				args.MaxResults = defaultMaxResults
+			}
+
+			validPath, err := t.resolvePath(ctx, name, args.Path, readPath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			info, err := t.v.Stat(validPath)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error reading directory: %v", err)), nil
+			}
+			if !info.IsDir() {
+				return ErrorResult(fmt.Sprintf("%s is not a directory", validPath)), nil
+			}
+
+			var ignore *filesystem.IgnoreFilter
+			if t.opts.HonorIgnoreFiles && !args.IncludeIgnored {
+				ignore = t.v.NewIgnoreFilter()
+			}
+
+			// Progress is reported in entries walked
+			progress := filesystem.TrackProgress(ctx, 0)
+			defer progress.Finish()
+
+			var matches []string
+			more := false
+			err = t.v.WalkDir(ctx, validPath, func(path string, d fs.DirEntry, err error) error {
+				if path == validPath {
+					return err
+				}
+				progress.Add(1)
+				if err != nil {
+					// Unreadable entries are left out rather than failing the search
+					if d != nil && d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+
+				rel, err := filepath.Rel(validPath, path)
+				if err != nil {
+					return err
+				}
+				rel = filepath.ToSlash(rel)
+				depth := strings.Count(rel, "/") + 1
+
+				if excluded(rel, args.ExcludePatterns) ||
+					(ignore != nil && ignore.Ignored(path, d.IsDir())) ||
+					t.v.Authorize(name, path) != nil {
+					if d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+
+				if filesystem.MatchGlob(args.Pattern, rel) {
+					if len(matches) == args.MaxResults {
+						more = true
+						return fs.SkipAll
+					}
+					if d.IsDir() {
+						rel += "/"
+					}
+					matches = append(matches, rel)
+				}
+
+				if d.IsDir() && args.MaxDepth > 0 && depth >= args.MaxDepth {
+					return fs.SkipDir
+				}
+				return nil
+			})
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error searching directory: %v", err)), nil
+			}
+
+			if len(matches) == 0 {
+				return TextResult(fmt.Sprintf("No paths under %s match %s", validPath, args.Pattern)), nil
+			}
+			text := strings.Join(matches, "\n")
+			if more {
+				text += fmt.Sprintf("\n\n[Showing the first %d matches; narrow the pattern or raise maxResults to see more.]", args.MaxResults)
+			}
+			return TextResult(text), nil
+		}),
+	}
+}
+
+// excluded reports whether a relative path matches any of the patterns
+func excluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if filesystem.MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
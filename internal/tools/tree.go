@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"mcp-filesystem-server/internal/filesystem"
+)
+
+// Defaults of directory_tree's limits
+const (
+	defaultTreeDepth   = 3
+	defaultTreeEntries = 100
+)
+
+// TreeEntry is a file or directory in the result of directory_tree
+type TreeEntry struct {
+	Name string `json:"name"`
+	// Type is file, directory, symlink or other
+	Type string `json:"type"`
+	// Size is set for files
+	Size *int64 `json:"size,omitempty"`
+	// Children lists the entries of a directory. Truncated marks a directory
+	// below maxDepth whose entries are not listed, and Omitted counts the
+	// entries left out beyond maxEntries.
+	Children  []*TreeEntry `json:"children,omitzero"`
+	Truncated bool         `json:"truncated,omitempty"`
+	Omitted   int          `json:"omitted,omitempty"`
+	// Error says why a directory could not be read
+	Error string `json:"error,omitempty"`
+}
+
+type directoryTreeArgs struct {
+	Path           string `json:"path"`
+	MaxDepth       int    `json:"maxDepth"`
+	MaxEntries     int    `json:"maxEntries"`
+	IncludeIgnored bool   `json:"includeIgnored"`
+}
+
+func (t *toolset) directoryTree() Tool {
+	const name = "directory_tree"
+	return Tool{
+		Name: name,
+		Description: "Get the layout of a directory in one call as a nested JSON tree of entries with name, type " +
+			"(file, directory, symlink or other), size of files and children of directories. Directories deeper than " +
+			"maxDepth are marked truncated, and entries beyond maxEntries in a directory are counted in omitted.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path":           stringProperty("Path to the directory to describe"),
+				"maxDepth":       integerProperty(fmt.Sprintf("Number of directory levels to list, 1 for direct entries only (default %d)", defaultTreeDepth), 1),
+				"maxEntries":     integerProperty(fmt.Sprintf("Maximum number of entries to list per directory (default %d)", defaultTreeEntries), 1),
+				"includeIgnored": booleanProperty("Include entries matched by .gitignore and .mcpignore files"),
+			},
+			Required: []string{"path"},
+		},
+		OutputSchema: &Schema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name":      map[string]interface{}{"type": "string"},
+				"type":      map[string]interface{}{"type": "string", "enum": []string{"file", "directory", "symlink", "other"}},
+				"size":      map[string]interface{}{"type": "integer"},
+				"children":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+				"truncated": map[string]interface{}{"type": "boolean"},
+				"omitted":   map[string]interface{}{"type": "integer"},
+				"error":     map[string]interface{}{"type": "string"},
+			},
+			Required: []string{"name", "type"},
+		},
+		Annotations: Annotations{Title: "Directory Tree", ReadOnly: true, Idempotent: true},
+		Handler: Typed(func(ctx context.Context, args directoryTreeArgs) (*Result, error) {
+			switch {
+			case args.MaxDepth < 0:
+				return nil, &ArgumentError{Message: "Invalid parameter maxDepth: must be at least 1"}
+			case args.MaxEntries < 0:
+				return nil, &ArgumentError{Message: "Invalid parameter maxEntries: must be at least 1"}
+			}
+			if args.MaxDepth == 0 {
+				args.MaxDepth = defaultTreeDepth
+			}
+			if args.MaxEntries == 0 {
+				args.MaxEntries = defaultTreeEntries
+			}
+
+			validPath, err := t.resolvePath(ctx, name, args.Path, readPath)
+			if err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+
+			info, err := t.v.Stat(validPath)
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error reading directory: %v", err)), nil
+			}
+			if !info.IsDir() {
+				return ErrorResult(fmt.Sprintf("%s is not a directory", validPath)), nil
+			}
+
+			var ignore *filesystem.IgnoreFilter
+			if t.opts.HonorIgnoreFiles && !args.IncludeIgnored {
+				ignore = t.v.NewIgnoreFilter()
+			}
+
+			root := &TreeEntry{Name: filepath.Base(validPath), Type: "directory"}
+			if err := t.fillTree(ctx, name, root, validPath, args, ignore, 1); err != nil {
+				return ErrorResult(fmt.Sprintf("Error reading directory: %v", err)), nil
+			}
+
+			text, err := json.MarshalIndent(root, "", "  ")
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("Error encoding tree: %v", err)), nil
+			}
+			result := TextResult(string(text))
+			result.Structured = root
+			return result, nil
+		}),
+	}
+}
+
+// fillTree lists the entries of the directory at path, which is depth
+// levels below the root of the tree, into entry
+func (t *toolset) fillTree(ctx context.Context, name string, entry *TreeEntry, path string, args directoryTreeArgs, ignore *filesystem.IgnoreFilter, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	files, err := t.v.ReadDir(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return nil
+	}
+
+	entry.Children = []*TreeEntry{}
+	for _, file := range files {
+		childPath := filepath.Join(path, file.Name())
+		if (ignore != nil && ignore.Ignored(childPath, file.IsDir())) || t.v.Authorize(name, childPath) != nil {
+			continue
+		}
+		if len(entry.Children) == args.MaxEntries {
+			entry.Omitted++
+			continue
+		}
+
+		child := &TreeEntry{Name: file.Name()}
+		entry.Children = append(entry.Children, child)
+		switch mode := file.Type(); {
+		case mode.IsDir():
+			child.Type = "directory"
+			if depth >= args.MaxDepth {
+				child.Truncated = true
+			} else if err := t.fillTree(ctx, name, child, childPath, args, ignore, depth+1); err != nil {
+				return err
+			}
+		case mode&fs.ModeSymlink != 0:
+			child.Type = "symlink"
+		case mode.IsRegular():
+			child.Type = "file"
+			if info, err := file.Info(); err == nil {
+				size := info.Size()
+				child.Size = &size
+			}
+		default:
+			child.Type = "other"
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+//go:build linux
+
+package watcher
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// watchMask selects the inotify events the watcher reacts to. Symlinks are
+// never followed, so a link cannot pull directories outside the tree in.
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF |
+	syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW
+
+// Watcher watches directory trees with inotify. inotify is not recursive, so
+// a watch is added for every directory, including ones created later.
+type Watcher struct {
+	fd        int
+	file      *os.File
+	opts      Options
+	onError   func(error)
+	dirs      map[int32]string
+	debouncer *debouncer
+}
+
+// New starts watching the given directories recursively and calls handler
+// with each debounced batch of events. onError, which may be nil, receives
+// problems such as running out of inotify watches; the watcher keeps going.
+func New(dirs []string, opts Options, handler func([]Event), onError func(error)) (*Watcher, error) {
+	// A non-blocking descriptor lets the runtime poller wait on it, so that
+	// Close interrupts a pending read. The descriptor is kept separately
+	// because File.Fd would switch it back to blocking mode.
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing inotify: %v", err)
+	}
+
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	w := &Watcher{
+		fd:        fd,
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		opts:      opts,
+		onError:   onError,
+		dirs:      make(map[int32]string),
+		debouncer: newDebouncer(opts.Debounce, opts.MaxWait, handler),
+	}
+
+	for _, dir := range dirs {
+		w.addTree(dir, false)
+	}
+
+	go w.readEvents()
+
+	return w, nil
+}
+
+// Close stops watching and discards events not yet delivered
+func (w *Watcher) Close() error {
+	w.debouncer.stop()
+	return w.file.Close()
+}
+
+// addTree watches a directory and every directory below it. With report
+// set, entries found are reported as created, which covers files written
+// into a new directory before its watch was in place.
+func (w *Watcher) addTree(dir string, report bool) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if path != dir && w.skip(path, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if report && path != dir {
+			w.debouncer.add(path, Create)
+		}
+
+		if d.IsDir() {
+			wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+			if err != nil {
+				w.onError(fmt.Errorf("error watching %s: %v", path, err))
+				return fs.SkipDir
+			}
+			w.dirs[int32(wd)] = path
+		}
+		return nil
+	})
+}
+
+// removeTree forgets the watches of a directory moved out of the tree and of
+// everything below it. The kernel keeps those watches on the moved
+// directory, so they are removed explicitly.
+func (w *Watcher) removeTree(dir string) {
+	for wd, path := range w.dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// readEvents reads and dispatches inotify events until the watcher is closed
+func (w *Watcher) readEvents() {
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.onError(fmt.Errorf("error reading inotify events: %v", err))
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+nameLen]), "\x00")
+			offset = nameStart + nameLen
+
+			w.handleEvent(wd, mask, name)
+		}
+	}
+}
+
+// handleEvent translates a single inotify event into watcher events
+func (w *Watcher) handleEvent(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.onError(fmt.Errorf("inotify event queue overflowed; some changes were not reported"))
+		return
+	}
+
+	dir, ok := w.dirs[wd]
+	if !ok {
+		return
+	}
+
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	isDir := mask&syscall.IN_ISDIR != 0
+
+	if w.skip(path, isDir) {
+		return
+	}
+
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		w.debouncer.add(path, Create)
+		if isDir {
+			w.addTree(path, true)
+		}
+	case mask&syscall.IN_MOVED_FROM != 0:
+		w.debouncer.add(path, Remove)
+		if isDir {
+			w.removeTree(path)
+		}
+	case mask&syscall.IN_DELETE != 0:
+		w.debouncer.add(path, Remove)
+	case mask&syscall.IN_MODIFY != 0:
+		w.debouncer.add(path, Write)
+	case mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 && name == "":
+		// Matters for watched roots; subdirectories also report this
+		// through their parent and the debouncer merges the two
+		w.debouncer.add(path, Remove)
+	}
+}
+
+// skip applies the Skip option
+func (w *Watcher) skip(path string, isDir bool) bool {
+	return w.opts.Skip != nil && w.opts.Skip(path, isDir)
+}
@@ -0,0 +1,175 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watch starts a watcher on dir and returns its batches
+func watch(t *testing.T, dir string, opts Options) chan []Event {
+	t.Helper()
+	deliver, batches := collect()
+	w, err := New([]string{dir}, opts, deliver, func(err error) { t.Errorf("watcher: %v", err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return batches
+}
+
+// waitFor collects batches until every path in want has been reported
+// with at least the given operations, and returns what was seen
+func waitFor(t *testing.T, batches chan []Event, want map[string]Op) map[string]Op {
+	t.Helper()
+	seen := make(map[string]Op)
+	deadline := time.After(5 * time.Second)
+	for {
+		done := true
+		for path, op := range want {
+			if seen[path]&op != op {
+				done = false
+			}
+		}
+		if done {
+			return seen
+		}
+
+		select {
+		case events := <-batches:
+			for _, event := range events {
+				seen[event.Path] |= event.Op
+			}
+		case <-deadline:
+			t.Fatalf("got %v, want at least %v", seen, want)
+		}
+	}
+}
+
+func TestWatcherReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	batches := watch(t, dir, Options{Debounce: 10 * time.Millisecond})
+
+	if err := os.WriteFile(file, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, batches, map[string]Op{file: Write})
+
+	created := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(created, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, batches, map[string]Op{created: Create})
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, batches, map[string]Op{file: Remove})
+
+	moved := filepath.Join(dir, "moved.txt")
+	if err := os.Rename(created, moved); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, batches, map[string]Op{created: Remove, moved: Create})
+}
+
+func TestWatcherNewDirectories(t *testing.T) {
+	dir := t.TempDir()
+	batches := watch(t, dir, Options{Debounce: 10 * time.Millisecond})
+
+	// Files written right after creating a directory may land before its
+	// watch is in place and are reported from the scan of the new tree
+	nested := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	early := filepath.Join(nested, "early.txt")
+	if err := os.WriteFile(early, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, batches, map[string]Op{filepath.Join(dir, "a"): Create, early: Create})
+
+	// Later changes in the new directory are watched directly
+	late := filepath.Join(nested, "late.txt")
+	if err := os.WriteFile(late, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, batches, map[string]Op{late: Create})
+}
+
+func TestWatcherSkip(t *testing.T) {
+	dir := t.TempDir()
+	skipped := filepath.Join(dir, "node_modules")
+	if err := os.Mkdir(skipped, 0755); err != nil {
+		t.Fatal(err)
+	}
+	batches := watch(t, dir, Options{
+		Debounce: 10 * time.Millisecond,
+		Skip: func(path string, isDir bool) bool {
+			return filepath.Base(path) == "node_modules" || strings.HasSuffix(path, ".log")
+		},
+	})
+
+	for _, path := range []string{filepath.Join(skipped, "pkg.js"), filepath.Join(dir, "debug.log")} {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marker := filepath.Join(dir, "marker.txt")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := waitFor(t, batches, map[string]Op{marker: Create})
+	for path := range seen {
+		if path != marker {
+			t.Errorf("skipped path %s was reported", path)
+		}
+	}
+}
+
+func TestWatcherContinuousWrites(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "busy.log")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	batches := watch(t, dir, Options{Debounce: 50 * time.Millisecond, MaxWait: 150 * time.Millisecond})
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				f.WriteString("line\n")
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	select {
+	case events := <-batches:
+		if len(events) != 1 || events[0].Path != file || events[0].Op&Write == 0 {
+			t.Errorf("got %v, want a write to %s", events, file)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a file written continuously was never reported")
+	}
+}
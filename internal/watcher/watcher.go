@@ -0,0 +1,115 @@
+// Package watcher reports changes to files under a set of directories,
+// watching them recursively and debouncing bursts of events into batches.
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Op describes what happened to a path
+type Op uint8
+
+const (
+	// Create means the path was created or moved into a watched directory
+	Create Op = 1 << iota
+	// Write means the contents of the path changed
+	Write
+	// Remove means the path was removed or moved out of a watched directory
+	Remove
+)
+
+// Event is a change to a single path. Op may combine several operations when
+// the path changed more than once within the debounce interval.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Options configures a Watcher
+type Options struct {
+	// Debounce is how long the watcher waits for more changes before
+	// delivering a batch
+	Debounce time.Duration
+	// MaxWait bounds how long changes that keep arriving can hold a batch
+	// back, so that a file written continuously is still reported. Zero
+	// means ten times Debounce.
+	MaxWait time.Duration
+	// Skip, when set, excludes directories from being watched and paths
+	// from being reported
+	Skip func(path string, isDir bool) bool
+}
+
+// debouncer coalesces events per path and delivers them once no new events
+// arrived for the debounce interval, or once the oldest pending event has
+// waited maxWait
+type debouncer struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	maxWait time.Duration
+	pending map[string]Op
+	order   []string
+	first   time.Time
+	timer   *time.Timer
+	deliver func([]Event)
+}
+
+func newDebouncer(delay, maxWait time.Duration, deliver func([]Event)) *debouncer {
+	if maxWait <= 0 {
+		maxWait = 10 * delay
+	}
+	return &debouncer{
+		delay:   delay,
+		maxWait: max(maxWait, delay),
+		pending: make(map[string]Op),
+		deliver: deliver,
+	}
+}
+
+// add records an event and restarts the debounce timer, without moving the
+// delivery past maxWait after the first pending event
+func (d *debouncer) add(path string, op Op) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.order) == 0 {
+		d.first = time.Now()
+	}
+	if _, ok := d.pending[path]; !ok {
+		d.order = append(d.order, path)
+	}
+	d.pending[path] |= op
+
+	wait := min(d.delay, d.maxWait-time.Since(d.first))
+	if d.timer == nil {
+		d.timer = time.AfterFunc(wait, d.flush)
+	} else {
+		d.timer.Reset(wait)
+	}
+}
+
+// flush delivers the pending events in the order their paths first changed
+func (d *debouncer) flush() {
+	d.mu.Lock()
+	events := make([]Event, 0, len(d.order))
+	for _, path := range d.order {
+		events = append(events, Event{Path: path, Op: d.pending[path]})
+	}
+	d.pending = make(map[string]Op)
+	d.order = nil
+	d.mu.Unlock()
+
+	if len(events) > 0 {
+		d.deliver(events)
+	}
+}
+
+// stop cancels any pending delivery
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
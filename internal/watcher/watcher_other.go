@@ -0,0 +1,22 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Watcher is not available on this platform
+type Watcher struct{}
+
+// New reports that watching is unsupported; callers should carry on without
+// change notifications
+func New(dirs []string, opts Options, handler func([]Event), onError func(error)) (*Watcher, error) {
+	return nil, fmt.Errorf("%w: file watching requires inotify (Linux)", errors.ErrUnsupported)
+}
+
+// Close does nothing
+func (w *Watcher) Close() error {
+	return nil
+}
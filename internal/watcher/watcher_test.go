@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// collect returns a deliver function that sends each batch on a channel
+func collect() (func([]Event), chan []Event) {
+	batches := make(chan []Event, 100)
+	return func(events []Event) { batches <- events }, batches
+}
+
+// next waits for the next batch
+func next(t *testing.T, batches chan []Event, timeout time.Duration) []Event {
+	t.Helper()
+	select {
+	case events := <-batches:
+		return events
+	case <-time.After(timeout):
+		t.Fatalf("no batch delivered within %v", timeout)
+		return nil
+	}
+}
+
+func TestDebouncerMergesEvents(t *testing.T) {
+	deliver, batches := collect()
+	d := newDebouncer(20*time.Millisecond, 0, deliver)
+	defer d.stop()
+
+	d.add("b", Create)
+	d.add("a", Write)
+	d.add("b", Write)
+
+	want := []Event{{Path: "b", Op: Create | Write}, {Path: "a", Op: Write}}
+	if got := next(t, batches, time.Second); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	select {
+	case events := <-batches:
+		t.Errorf("unexpected second batch %v", events)
+	case <-time.After(60 * time.Millisecond):
+	}
+}
+
+func TestDebouncerMaxWait(t *testing.T) {
+	deliver, batches := collect()
+	d := newDebouncer(50*time.Millisecond, 150*time.Millisecond, deliver)
+	defer d.stop()
+
+	// Events closer together than the debounce interval would otherwise
+	// postpone delivery for as long as they keep coming
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for time.Since(start) < 600*time.Millisecond {
+			d.add("busy", Write)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	events := next(t, batches, 400*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("first batch after %v, want about 150ms", elapsed)
+	}
+	if want := []Event{{Path: "busy", Op: Write}}; !slices.Equal(events, want) {
+		t.Errorf("got %v, want %v", events, want)
+	}
+
+	// The writes go on, so later batches follow
+	next(t, batches, 400*time.Millisecond)
+	<-done
+}
+
+func TestDebouncerStop(t *testing.T) {
+	deliver, batches := collect()
+	d := newDebouncer(20*time.Millisecond, 0, deliver)
+
+	d.add("a", Create)
+	d.stop()
+
+	select {
+	case events := <-batches:
+		t.Errorf("batch %v delivered after stop", events)
+	case <-time.After(60 * time.Millisecond):
+	}
+}